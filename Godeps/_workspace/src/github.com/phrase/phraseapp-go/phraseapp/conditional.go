@@ -0,0 +1,75 @@
+package phraseapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// Validators are the ETag and Last-Modified headers of a response. They are
+// sent with conditional requests to only receive resources that changed.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// LocaleDownloadResponse is the result of a conditional locale download.
+type LocaleDownloadResponse struct {
+	Validators
+
+	Content     []byte
+	NotModified bool
+}
+
+// Download a locale in a specific file format, unless it didn't change since
+// the response the validators belong to.
+func (client *Client) LocaleDownloadIfModified(project_id, id string, params *LocaleDownloadParams, validators *Validators) (*LocaleDownloadResponse, error) {
+	url := fmt.Sprintf("%s/v2/projects/%s/locales/%s/download", client.Credentials.Host, project_id, id)
+
+	paramsBuf := bytes.NewBuffer(nil)
+	err := json.NewEncoder(paramsBuf).Encode(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	if Debug {
+		fmt.Fprintln(os.Stderr, "GET", url)
+	}
+	req, err := http.NewRequest("GET", url, paramsBuf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
+
+	resp, err := client.send(req, 200)
+	if err == ErrNotModified {
+		return &LocaleDownloadResponse{NotModified: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &LocaleDownloadResponse{
+		Validators: Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+		Content: content,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package phraseapp
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -8,6 +9,10 @@ import (
 	"time"
 )
 
+// ErrNotModified is returned for conditional requests if the resource
+// didn't change.
+var ErrNotModified = errors.New("not modified")
+
 type ErrorResponse struct {
 	Message string
 }
@@ -16,6 +16,8 @@ func handleResponseStatus(resp *http.Response, expectedStatus int) error {
 	switch resp.StatusCode {
 	case expectedStatus:
 		return nil
+	case 304:
+		return ErrNotModified
 	case 400:
 		e := new(ErrorResponse)
 		err := json.NewDecoder(resp.Body).Decode(&e)
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// Name of the manifest describing the files of an archive.
+const archiveManifestName = "manifest.json"
+
+type archiveManifest struct {
+	CreatedAt time.Time              `json:"created_at"`
+	Files     []*archiveManifestFile `json:"files"`
+}
+
+type archiveManifestFile struct {
+	Path       string                          `json:"path"`
+	ProjectID  string                          `json:"project_id"`
+	LocaleID   string                          `json:"locale_id"`
+	LocaleCode string                          `json:"locale_code"`
+	LocaleName string                          `json:"locale_name"`
+	Tag        string                          `json:"tag,omitempty"`
+	Params     *phraseapp.LocaleDownloadParams `json:"params"`
+}
+
+// Checks that the archive format is known by the extension of the path.
+func checkArchivePath(path string) error {
+	if !isZip(path) && !isTarGz(path) {
+		return fmt.Errorf("archive %s must be a .zip, .tar.gz or .tgz file", path)
+	}
+	return nil
+}
+
+func isZip(path string) bool {
+	return strings.HasSuffix(path, ".zip")
+}
+
+func isTarGz(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// Returns the path of the locale file in an archive, which is its path
+// relative to the working directory.
+func archiveName(localeFile *LocaleFile) (string, error) {
+	name := filepath.ToSlash(localeFile.RelPath())
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("%s is outside of the working directory and can't be archived", localeFile.Path)
+	}
+	return name, nil
+}
+
+// Writes the downloaded content of the downloads and a manifest describing
+// them to the archive at path.
+func writeArchive(path string, downloads []*download, createdAt time.Time) error {
+	manifest := &archiveManifest{CreatedAt: createdAt.UTC(), Files: []*archiveManifestFile{}}
+	names := map[string]bool{archiveManifestName: true}
+	for _, d := range downloads {
+		name, err := archiveName(d.localeFile)
+		if err != nil {
+			return err
+		}
+		if names[name] {
+			return fmt.Errorf("%s is pulled more than once into %s", name, path)
+		}
+		names[name] = true
+
+		manifest.Files = append(manifest.Files, &archiveManifestFile{
+			Path:       name,
+			ProjectID:  d.target.ProjectID,
+			LocaleID:   d.localeFile.ID,
+			LocaleCode: d.localeFile.Code,
+			LocaleName: d.localeFile.Name,
+			Tag:        d.localeFile.Tag,
+			Params:     d.target.downloadParams(d.localeFile),
+		})
+	}
+
+	manifestContent, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	var w archiveWriter
+	if isZip(path) {
+		w = &zipArchiveWriter{zip.NewWriter(buf)}
+	} else {
+		gz := gzip.NewWriter(buf)
+		w = &tarArchiveWriter{tar.NewWriter(gz), gz}
+	}
+
+	for i, d := range downloads {
+		if err := w.add(manifest.Files[i].Path, d.remote, createdAt); err != nil {
+			return err
+		}
+	}
+	if err := w.add(archiveManifestName, append(manifestContent, '\n'), createdAt); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), defaultFileMode)
+}
+
+type archiveWriter interface {
+	io.Closer
+	add(name string, content []byte, modTime time.Time) error
+}
+
+type zipArchiveWriter struct {
+	*zip.Writer
+}
+
+func (w *zipArchiveWriter) add(name string, content []byte, modTime time.Time) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	header.SetModTime(modTime)
+	header.SetMode(defaultFileMode)
+	f, err := w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+type tarArchiveWriter struct {
+	*tar.Writer
+	gz *gzip.Writer
+}
+
+func (w *tarArchiveWriter) add(name string, content []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     int64(defaultFileMode),
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := w.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := w.Write(content)
+	return err
+}
+
+func (w *tarArchiveWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteArchive(t *testing.T) {
+	dir := setupFiles(t)
+	defer os.RemoveAll(dir)
+	defer pushd(t, dir)()
+
+	target := getBaseTarget()
+	downloads := []*download{}
+	for _, code := range []string{"en", "de"} {
+		localeFile := &LocaleFile{ID: code + "-locale-id", Code: code, Path: filepath.Join(dir, "locales", code+".yml")}
+		downloads = append(downloads, &download{target: target, localeFile: localeFile, remote: []byte(code + ":\n")})
+	}
+
+	createdAt := time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"out.zip", "out.tar.gz"} {
+		path := filepath.Join(dir, "dist", name)
+		if err := writeArchive(path, downloads, createdAt); err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+
+		files := readArchive(t, path)
+		if len(files) != 3 || files["locales/en.yml"] != "en:\n" || files["locales/de.yml"] != "de:\n" {
+			t.Errorf("%s: unexpected archive content %v", name, files)
+		}
+
+		manifest := new(archiveManifest)
+		if err := json.Unmarshal([]byte(files["manifest.json"]), manifest); err != nil {
+			t.Fatalf("%s: invalid manifest: %s", name, err)
+		}
+		if !manifest.CreatedAt.Equal(createdAt) || len(manifest.Files) != 2 {
+			t.Fatalf("%s: unexpected manifest %+v", name, manifest)
+		}
+		if f := manifest.Files[1]; f.Path != "locales/de.yml" || f.LocaleID != "de-locale-id" || f.LocaleCode != "de" || f.ProjectID != "project-id" {
+			t.Errorf("%s: unexpected manifest entry %+v", name, f)
+		}
+	}
+
+	downloads[1].localeFile.Path = filepath.Join(dir, "..", "de.yml")
+	if err := writeArchive(filepath.Join(dir, "out.zip"), downloads, createdAt); err == nil {
+		t.Errorf("expected an error for a file outside of the working directory")
+	}
+
+	if err := checkArchivePath("out.rar"); err == nil {
+		t.Errorf("expected an error for an unknown archive format")
+	}
+}
+
+func readArchive(t *testing.T, path string) map[string]string {
+	files := map[string]string{}
+	if isZip(path) {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[f.Name] = string(content)
+		}
+		return files
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := tar.NewReader(gz)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(content)
+	}
+}
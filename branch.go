@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+var (
+	branchTagInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+	branchTagDashes       = regexp.MustCompile(`--+`)
+)
+
+// Normalizes a git branch name for the use as tag, e.g. `feature/Login #2`
+// becomes `feature-Login-2`.
+func normalizeBranch(name string) string {
+	tag := branchTagInvalidChars.ReplaceAllString(name, "-")
+	return strings.Trim(branchTagDashes.ReplaceAllString(tag, "-"), "-")
+}
+
+// Environment variables CI systems set to the branch of a build, as they
+// usually check out a detached HEAD. Pull request branches come first.
+var ciBranchVariables = []string{
+	"GITHUB_HEAD_REF",
+	"GITHUB_REF_NAME",
+	"CI_COMMIT_REF_NAME",
+	"CIRCLE_BRANCH",
+	"TRAVIS_PULL_REQUEST_BRANCH",
+	"TRAVIS_BRANCH",
+	"BITBUCKET_BRANCH",
+	"BUILDKITE_BRANCH",
+	"BRANCH_NAME",
+	"GIT_BRANCH",
+}
+
+// Returns the normalized name of the checked out git branch. On a detached
+// HEAD the branch is taken from the environment of the CI system.
+func currentBranch() (string, error) {
+	out, err := git("symbolic-ref", "--short", "HEAD")
+	if err == nil {
+		return normalizeBranch(strings.TrimSpace(out)), nil
+	}
+	if branch := branchFromEnv(os.Getenv); branch != "" {
+		return normalizeBranch(branch), nil
+	}
+	return "", fmt.Errorf("the current git branch is required for <branch> and branch_tag, but it could not be determined (set it with --branch): %s", err)
+}
+
+func branchFromEnv(getenv func(string) string) string {
+	for _, name := range ciBranchVariables {
+		if branch := getenv(name); branch != "" {
+			// Jenkins includes the remote
+			return strings.TrimPrefix(branch, "origin/")
+		}
+	}
+	return ""
+}
+
+func (source *Source) usesBranch() bool {
+	tags := ""
+	if source.Params != nil && source.Params.Tags != nil {
+		tags = *source.Params.Tags
+	}
+	return source.BranchTag || strings.Contains(source.File, "<branch>") || strings.Contains(tags, "<branch>")
+}
+
+// Sets the branch of the source and replaces the <branch> placeholder in the
+// file pattern and the tags.
+func (source *Source) setBranch(branch string) {
+	source.Branch = branch
+	source.File = strings.Replace(source.File, "<branch>", branch, -1)
+	if source.Params != nil && source.Params.Tags != nil {
+		tags := strings.Replace(*source.Params.Tags, "<branch>", branch, -1)
+		source.Params.Tags = &tags
+	}
+}
+
+type BranchCleanupCommand struct {
+	*phraseapp.Config
+
+	Name string `cli:"arg required"`
+}
+
+func (cmd *BranchCleanupCommand) Run() error {
+	tag := normalizeBranch(cmd.Name)
+	if tag == "" {
+		return fmt.Errorf("invalid branch name %q", cmd.Name)
+	}
+
+	projectIDs, err := cmd.projectIDs()
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient(cmd.Config.Credentials)
+	if err != nil {
+		return err
+	}
+
+	q := "tags:" + tag
+	for _, projectID := range projectIDs {
+		res, err := client.KeysUntag(projectID, &phraseapp.KeysUntagParams{Q: &q, Tags: &tag})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed tag %s from %d keys in project %s\n", tag, res.RecordsAffected, projectID)
+	}
+	return nil
+}
+
+// Returns the projects of all push sources, or the default project if there
+// are no sources.
+func (cmd *BranchCleanupCommand) projectIDs() ([]string, error) {
+	projectIDs := []string{}
+	if len(cmd.Config.Sources) > 0 {
+		tmp := struct {
+			Sources Sources
+		}{}
+		if err := yaml.Unmarshal(cmd.Config.Sources, &tmp); err != nil {
+			return nil, err
+		}
+		for _, source := range tmp.Sources {
+			if source == nil {
+				continue
+			}
+			projectID := source.ProjectID
+			if projectID == "" {
+				projectID = cmd.Config.DefaultProjectID
+			}
+			if projectID != "" && !Contains(projectIDs, projectID) {
+				projectIDs = append(projectIDs, projectID)
+			}
+		}
+	}
+
+	if len(projectIDs) == 0 && cmd.Config.DefaultProjectID != "" {
+		projectIDs = append(projectIDs, cmd.Config.DefaultProjectID)
+	}
+	if len(projectIDs) == 0 {
+		return nil, fmt.Errorf("no project specified, set project_id in your configuration")
+	}
+	return projectIDs, nil
+}
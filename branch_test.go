@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+func TestNormalizeBranch(t *testing.T) {
+	tt := map[string]string{
+		"master":            "master",
+		"feature/login":     "feature-login",
+		"feature/Login #2":  "feature-Login-2",
+		"/fix//typo_1.2/":   "fix-typo_1.2",
+		"bugfix/über-ärger": "bugfix-ber-rger",
+	}
+
+	for name, exp := range tt {
+		if got := normalizeBranch(name); got != exp {
+			t.Errorf("expected %q to be normalized to %q, got %q", name, exp, got)
+		}
+	}
+}
+
+func TestSourcesFromConfigBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	d := setupFiles(t, "locales/feature-login/en.yml")
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"checkout", "-q", "-b", "feature/login"},
+	} {
+		if _, err := git(args...); err != nil {
+			t.Fatalf("git %v failed: %s", args, err)
+		}
+	}
+
+	cmd := &PushCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Sources = []byte(`
+sources:
+- file: ./locales/<branch>/<locale_code>.yml
+  branch_tag: true
+  params:
+    tags: app,<branch>-wip
+`)
+
+	sources, err := SourcesFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	source := sources[0]
+	if source.File != "./locales/feature-login/<locale_code>.yml" {
+		t.Errorf("expected the branch to be replaced in the file pattern, got %q", source.File)
+	}
+
+	params := source.uploadParams(&LocaleFile{Path: "locales/feature-login/en.yml", Code: "en", Tag: "web"})
+	if params.Tags == nil || *params.Tags != "app,feature-login-wip,web,feature-login" {
+		t.Errorf("unexpected tags %v", stringValue(params.Tags))
+	}
+}
+
+func TestTargetsFromConfigBranch(t *testing.T) {
+	cmd := &PullCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}, Branch: "feature/login"}
+	cmd.Config.Targets = []byte(`
+targets:
+- file: ./locales/<locale_code>.yml
+`)
+
+	targets, err := TargetsFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if tag := targets[0].GetTag(); tag != "feature-login" {
+		t.Errorf("expected tag feature-login, got %q", tag)
+	}
+	cmd.Config.Targets = []byte(`
+targets:
+- file: ./locales/<locale_code>.yml
+  params:
+    tag: web
+`)
+	if _, err := TargetsFromConfig(cmd); err == nil {
+		t.Errorf("expected an error for --branch with params.tag")
+	}
+}
+
+func TestSourcesFromConfigBranchOption(t *testing.T) {
+	cmd := &PushCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}, Branch: "feature/login"}
+	cmd.Config.Sources = []byte(`
+sources:
+- file: ./locales/<branch>/<locale_code>.yml
+`)
+
+	sources, err := SourcesFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if sources[0].File != "./locales/feature-login/<locale_code>.yml" {
+		t.Errorf("expected the branch of --branch in the file pattern, got %q", sources[0].File)
+	}
+}
+
+func TestBranchFromEnv(t *testing.T) {
+	tt := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"GIT_BRANCH": "origin/feature/login"}, "feature/login"},
+		{map[string]string{"GITHUB_REF_NAME": "main", "GITHUB_HEAD_REF": "feature/login"}, "feature/login"},
+		{map[string]string{"CI_COMMIT_REF_NAME": "fix"}, "fix"},
+	}
+
+	for _, tti := range tt {
+		getenv := func(name string) string { return tti.env[name] }
+		if got := branchFromEnv(getenv); got != tti.expected {
+			t.Errorf("expected branch %q for %v, got %q", tti.expected, tti.env, got)
+		}
+	}
+}
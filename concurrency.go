@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+var errSkipped = errors.New("skipped")
+
+// forEachParallel calls work for every index in [0, n) using at most workers
+// goroutines. The report function is called from the calling goroutine in
+// index order, so output stays grouped and stable regardless of which worker
+// finished first. With abortOnError set no work is started for indices above
+// the lowest failed one and the skipped indices are not reported. The error
+// of the lowest failed index is returned.
+func forEachParallel(n, workers int, abortOnError bool, work func(i int) error, report func(i int, err error)) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]chan error, n)
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+
+	// lowest failed index, n as long as nothing failed
+	failed := int64(n)
+	indices := make(chan int)
+	go func() {
+		for i := 0; i < n; i++ {
+			indices <- i
+		}
+		close(indices)
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range indices {
+				if abortOnError && int64(i) > atomic.LoadInt64(&failed) {
+					results[i] <- errSkipped
+					continue
+				}
+				err := work(i)
+				for err != nil {
+					lowest := atomic.LoadInt64(&failed)
+					if int64(i) >= lowest || atomic.CompareAndSwapInt64(&failed, lowest, int64(i)) {
+						break
+					}
+				}
+				results[i] <- err
+			}
+		}()
+	}
+
+	var firstErr error
+	for i := range results {
+		err := <-results[i]
+		if err == errSkipped {
+			continue
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		report(i, err)
+	}
+	return firstErr
+}
+
+const rateLimitRetries = 5
+
+// rateLimitGate is shared by all workers. Once a request is answered with a
+// RateLimitingError every worker waits until the limit is reset before
+// sending the next request.
+type rateLimitGate struct {
+	mutex      sync.Mutex
+	pauseUntil time.Time
+}
+
+var rateLimit = new(rateLimitGate)
+
+func (gate *rateLimitGate) wait() {
+	gate.mutex.Lock()
+	until := gate.pauseUntil
+	gate.mutex.Unlock()
+
+	if d := until.Sub(time.Now()); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+func (gate *rateLimitGate) pause(until time.Time) {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	if until.After(gate.pauseUntil) {
+		gate.pauseUntil = until
+	}
+}
+
+// do calls fn and retries it as long as the API responds with a rate limit
+// error, waiting for the reset time announced in the response headers.
+func (gate *rateLimitGate) do(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		gate.wait()
+
+		err := fn()
+		rle, ok := err.(*phraseapp.RateLimitingError)
+		if !ok || attempt >= rateLimitRetries {
+			return err
+		}
+
+		if Debug {
+			fmt.Fprintln(os.Stderr, rle)
+		}
+		gate.pause(rle.Reset.Add(time.Second))
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+func TestForEachParallelReportsInOrder(t *testing.T) {
+	for _, workers := range []int{0, 1, 4, 20} {
+		reported := []int{}
+		err := forEachParallel(10, workers, false, func(i int) error {
+			time.Sleep(time.Duration(10-i) * time.Millisecond)
+			return nil
+		}, func(i int, err error) {
+			reported = append(reported, i)
+		})
+		if err != nil {
+			t.Errorf("workers=%d: didn't expect an error, got: %s", workers, err)
+		}
+
+		if len(reported) != 10 {
+			t.Errorf("workers=%d: expected 10 reports, got %d", workers, len(reported))
+			continue
+		}
+		for i, got := range reported {
+			if got != i {
+				t.Errorf("workers=%d: expected index %d to be reported at position %d, got %d", workers, i, i, got)
+			}
+		}
+	}
+}
+
+func TestForEachParallelFirstError(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		failures := 0
+		err := forEachParallel(10, workers, false, func(i int) error {
+			if i%3 == 2 {
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				return fmt.Errorf("failed %d", i)
+			}
+			return nil
+		}, func(i int, err error) {
+			if err != nil {
+				failures++
+			}
+		})
+
+		if err == nil || err.Error() != "failed 2" {
+			t.Errorf("workers=%d: expected error of index 2, got: %v", workers, err)
+		}
+		if failures != 3 {
+			t.Errorf("workers=%d: expected all 3 failures to be reported, got %d", workers, failures)
+		}
+	}
+}
+
+func TestForEachParallelAbortOnError(t *testing.T) {
+	reported := 0
+	err := forEachParallel(10, 1, true, func(i int) error {
+		if i == 3 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	}, func(i int, err error) {
+		reported++
+	})
+
+	if err == nil || err.Error() != "failed 3" {
+		t.Errorf("expected error of index 3, got: %v", err)
+	}
+	if reported != 4 {
+		t.Errorf("expected 4 reports before aborting, got %d", reported)
+	}
+}
+
+func TestForEachParallelAbortOnErrorRunsLowerIndices(t *testing.T) {
+	// all indices fail at once, so workers that got a lower index race with
+	// the failures of higher ones
+	for run := 0; run < 100; run++ {
+		reported := []int{}
+		err := forEachParallel(20, 20, true, func(i int) error {
+			return fmt.Errorf("failed %d", i)
+		}, func(i int, err error) {
+			reported = append(reported, i)
+		})
+
+		if err == nil || err.Error() != "failed 0" {
+			t.Fatalf("run %d: expected error of index 0, got: %v", run, err)
+		}
+		if len(reported) == 0 || reported[0] != 0 {
+			t.Fatalf("run %d: expected index 0 to be reported, got %v", run, reported)
+		}
+	}
+}
+
+func TestRateLimitGateRetries(t *testing.T) {
+	gate := new(rateLimitGate)
+
+	calls := 0
+	err := gate.do(func() error {
+		calls++
+		if calls < 3 {
+			return &phraseapp.RateLimitingError{Reset: time.Now().Add(-time.Second)}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("didn't expect an error, got: %s", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+const configName = ".phraseapp.yml"
+
+// clientConfig holds the top-level configuration keys only the client knows
+// about. As the API library rejects unknown keys, the configuration is
+// parsed here, with the keys of the library and those of the client.
+type clientConfig struct {
+	LocaleMapping LocaleMapping
+	Hooks         []byte
+}
+
+// Reads the configuration from the same file as phraseapp.ReadConfig.
+func readConfig() (*phraseapp.Config, *clientConfig, error) {
+	cfg := &phraseapp.Config{Credentials: new(phraseapp.Credentials)}
+	clientCfg := new(clientConfig)
+
+	path, err := configPath()
+	if err != nil || path == "" {
+		return cfg, clientCfg, err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, clientCfg, parseConfig(content, cfg, clientCfg)
+}
+
+// Returns the path of the configuration file: the one given in
+// PHRASEAPP_CONFIG, the one in the working directory or the one in the home
+// directory, or an empty path if there is none.
+func configPath() (string, error) {
+	if envConfig := os.Getenv("PHRASEAPP_CONFIG"); envConfig != "" {
+		switch _, err := os.Stat(envConfig); {
+		case err == nil:
+			return envConfig, nil
+		case os.IsNotExist(err):
+			return "", fmt.Errorf("file %q (given in PHRASEAPP_CONFIG) doesn't exist", envConfig)
+		default:
+			return "", err
+		}
+	}
+
+	home := os.Getenv("HOME")
+	if runtime.GOOS == "windows" {
+		home = os.Getenv("HomePath")
+	}
+	for _, path := range []string{configName, filepath.Join(home, configName)} {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+func parseConfig(content []byte, cfg *phraseapp.Config, clientCfg *clientConfig) error {
+	file := struct {
+		PhraseApp *configParser
+	}{&configParser{cfg: cfg, clientCfg: clientCfg}}
+	return yaml.Unmarshal(content, &file)
+}
+
+type configParser struct {
+	cfg       *phraseapp.Config
+	clientCfg *clientConfig
+}
+
+func (p *configParser) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	cfg := p.cfg
+	defaults, mapping := map[string]interface{}{}, map[string]interface{}{}
+	err := phraseapp.ParseYAMLToMap(unmarshal, map[string]interface{}{
+		"access_token":   &cfg.Credentials.Token,
+		"host":           &cfg.Credentials.Host,
+		"debug":          &cfg.Credentials.Debug,
+		"page":           &cfg.Page,
+		"perpage":        &cfg.PerPage,
+		"project_id":     &cfg.DefaultProjectID,
+		"file_format":    &cfg.DefaultFileFormat,
+		"push":           &cfg.Sources,
+		"pull":           &cfg.Targets,
+		"defaults":       &defaults,
+		"hooks":          &p.clientCfg.Hooks,
+		"locale_mapping": &mapping,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the pull config is re-marshalled by the generic parsing, which turns
+	// modes like 0644 into decimal numbers, so it is read again with the
+	// modes kept as written
+	pull := struct {
+		Pull *pullSection `yaml:"pull"`
+	}{}
+	if err := unmarshal(&pull); err != nil {
+		return err
+	}
+	if pull.Pull != nil {
+		cfg.Targets = []byte(*pull.Pull)
+	}
+
+	cfg.Defaults = map[string]map[string]interface{}{}
+	for path, rawConfig := range defaults {
+		if cfg.Defaults[path], err = phraseapp.ValidateIsRawMap("defaults."+path, rawConfig); err != nil {
+			return err
+		}
+	}
+
+	localeMapping, err := phraseapp.ConvertToStringMap(mapping)
+	if err != nil {
+		return err
+	}
+	p.clientCfg.LocaleMapping = LocaleMapping(localeMapping)
+	return nil
+}
+
+// pullSection is the marshalled pull config with file_mode and dir_mode, of
+// the config and of every target, kept as written.
+type pullSection []byte
+
+func (cfg *pullSection) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	if m, ok := raw.(map[interface{}]interface{}); ok {
+		modes := struct {
+			Modes   rawModes `yaml:",inline"`
+			Targets []rawModes
+		}{}
+		if err := unmarshal(&modes); err != nil {
+			return err
+		}
+		modes.Modes.apply(m)
+
+		targets, _ := m["targets"].([]interface{})
+		for i, target := range targets {
+			if t, ok := target.(map[interface{}]interface{}); ok && i < len(modes.Targets) {
+				modes.Targets[i].apply(t)
+			}
+		}
+	}
+
+	content, err := yaml.Marshal(raw)
+	*cfg = content
+	return err
+}
+
+// rawModes are file_mode and dir_mode as written in the config.
+type rawModes struct {
+	FileMode *string `yaml:"file_mode"`
+	DirMode  *string `yaml:"dir_mode"`
+}
+
+func (modes rawModes) apply(m map[interface{}]interface{}) {
+	if modes.FileMode != nil {
+		m["file_mode"] = *modes.FileMode
+	}
+	if modes.DirMode != nil {
+		m["dir_mode"] = *modes.DirMode
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg := &phraseapp.Config{Credentials: new(phraseapp.Credentials)}
+	clientCfg := new(clientConfig)
+	err := parseConfig([]byte(`
+phraseapp:
+  access_token: some_token
+  project_id: project-id
+  defaults:
+    locales/download:
+      file_format: yml
+  locale_mapping:
+    pt_BR: Portuguese (Brazil)
+    zh-Hant: zh-hant-locale-id
+  hooks:
+    before_push: make strings
+  pull:
+    file_mode: 0640
+    targets:
+    - file: ./locales/<locale_code>.yml
+      dir_mode: 0750
+`), cfg, clientCfg)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	if cfg.Credentials.Token != "some_token" || cfg.DefaultProjectID != "project-id" {
+		t.Errorf("unexpected credentials %q and project %q", cfg.Credentials.Token, cfg.DefaultProjectID)
+	}
+	if cfg.Defaults["locales/download"]["file_format"] != "yml" {
+		t.Errorf("unexpected defaults %v", cfg.Defaults)
+	}
+	if !strings.Contains(string(cfg.Targets), "./locales/<locale_code>.yml") {
+		t.Errorf("unexpected pull config %q", cfg.Targets)
+	}
+	targets, err := TargetsFromConfig(&PullCommand{Config: cfg})
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if len(targets) != 1 || targets[0].FileMode != 0640 || targets[0].DirMode != 0750 {
+		t.Errorf("expected the modes to be read as octal numbers, got %v", targets)
+	}
+	if len(clientCfg.LocaleMapping) != 2 || clientCfg.LocaleMapping["pt_BR"] != "Portuguese (Brazil)" {
+		t.Errorf("unexpected locale mapping %v", clientCfg.LocaleMapping)
+	}
+	if !strings.Contains(string(clientCfg.Hooks), "make strings") {
+		t.Errorf("unexpected hooks %q", clientCfg.Hooks)
+	}
+
+	err = parseConfig([]byte("phraseapp:\n  locale_mappings: {}\n"), cfg, clientCfg)
+	if err == nil || !strings.Contains(err.Error(), `"locale_mappings" unknown`) {
+		t.Errorf("expected an unknown key error, got: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn"
+
+	expected := `--- en.yml
++++ en.yml (PhraseApp)
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+\ No newline at end of file
+`
+	if diff := unifiedDiff("en.yml", "en.yml (PhraseApp)", []byte(from), []byte(to)); diff != expected {
+		t.Errorf("expected diff\n%s\ngot\n%s", expected, diff)
+	}
+
+	if diff := unifiedDiff("en.yml", "en.yml", []byte(from), []byte(from)); diff != "" {
+		t.Errorf("expected no diff for equal contents, got\n%s", diff)
+	}
+
+	expected = "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if diff := unifiedDiff("a", "b", nil, []byte("x\ny\n")); diff != expected {
+		t.Errorf("expected diff\n%s\ngot\n%s", expected, diff)
+	}
+}
+
+func TestDiffLinesIsShortest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		l := make([]string, r.Intn(12))
+		for i := range l {
+			l[i] = string('a' + byte(r.Intn(4)))
+		}
+		return l
+	}
+
+	for run := 0; run < 1000; run++ {
+		a, b := lines(), lines()
+		ops := diffLines(a, b)
+
+		from, to, edits := []string{}, []string{}, 0
+		for _, op := range ops {
+			if op.kind != '+' {
+				from = append(from, op.line)
+			}
+			if op.kind != '-' {
+				to = append(to, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		if fmt.Sprint(from) != fmt.Sprint(a) || fmt.Sprint(to) != fmt.Sprint(b) {
+			t.Fatalf("%v -> %v: edit script %v doesn't turn one into the other", a, b, ops)
+		}
+		if shortest := len(a) + len(b) - 2*lcsLength(a, b); edits != shortest {
+			t.Fatalf("%v -> %v: expected %d edits, got %d", a, b, shortest, edits)
+		}
+	}
+}
+
+func TestDiffLinesOfLargeFiles(t *testing.T) {
+	a, b := make([]string, 4000), make([]string, 4000)
+	for i := range a {
+		a[i], b[i] = fmt.Sprintf("a%d\n", i), fmt.Sprintf("b%d\n", i)
+	}
+
+	allocs := testing.AllocsPerRun(1, func() {
+		if ops := diffLines(a, b); len(ops) != 8000 {
+			t.Errorf("expected 8000 edits, got %d", len(ops))
+		}
+	})
+	if allocs > 10000 {
+		t.Errorf("expected few allocations, got %.0f", allocs)
+	}
+}
+
+func lcsLength(a, b []string) int {
+	l := make([][]int, len(a)+1)
+	for i := range l {
+		l[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				l[i][j] = l[i+1][j+1] + 1
+			case l[i+1][j] > l[i][j+1]:
+				l[i][j] = l[i+1][j]
+			default:
+				l[i][j] = l[i][j+1]
+			}
+		}
+	}
+	return l[0][0]
+}
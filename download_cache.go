@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+)
+
+// Directory of the files the client keeps between runs. It is never searched
+// for locale files.
+const stateDir = ".phraseapp"
+
+var downloadCachePath = filepath.Join(stateDir, "cache.yml")
+
+// downloadCache records the ETag and Last-Modified headers of pulled files,
+// so that following pulls only download locales that changed. Entries are
+// grouped by project and keyed by the path relative to the working directory,
+// like the entries of the Lockfile.
+type downloadCache struct {
+	path string
+
+	mutex    sync.Mutex
+	Projects map[string]map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	LocaleID     string `yaml:"locale_id"`
+	Params       string `yaml:"params"`
+	ETag         string `yaml:"etag,omitempty"`
+	LastModified string `yaml:"last_modified,omitempty"`
+
+	// Hash is the hash of the written file. The validators are only sent as
+	// long as the local file wasn't changed.
+	Hash string `yaml:"hash"`
+}
+
+func loadDownloadCache(path string) (*downloadCache, error) {
+	cache := &downloadCache{path: path, Projects: map[string]map[string]*cacheEntry{}}
+
+	content, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return cache, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, &cache.Projects); err != nil {
+		return nil, fmt.Errorf("%s is invalid: %s", path, err)
+	}
+	if cache.Projects == nil {
+		cache.Projects = map[string]map[string]*cacheEntry{}
+	}
+	return cache, nil
+}
+
+func (cache *downloadCache) save() error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	content, err := yaml.Marshal(cache.Projects)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cache.path), defaultDirMode); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cache.path, content, defaultFileMode)
+}
+
+// Returns the validators to send when downloading the locale file, or nil if
+// the file must be downloaded unconditionally.
+func (cache *downloadCache) validators(target *Target, localeFile *LocaleFile) *phraseapp.Validators {
+	cache.mutex.Lock()
+	entry, found := cache.Projects[target.ProjectID][localeFile.RelPath()]
+	cache.mutex.Unlock()
+
+	if !found || entry.LocaleID != localeFile.ID || entry.Params != target.contentParams(localeFile) {
+		return nil
+	}
+	if hash, err := fileHash(localeFile.Path); err != nil || hash != entry.Hash {
+		return nil
+	}
+	return &phraseapp.Validators{ETag: entry.ETag, LastModified: entry.LastModified}
+}
+
+// Records the validators of the written locale file. Responses without
+// validators remove the entry.
+func (cache *downloadCache) record(target *Target, localeFile *LocaleFile, validators phraseapp.Validators) error {
+	var entry *cacheEntry
+	if validators.ETag != "" || validators.LastModified != "" {
+		hash, err := fileHash(localeFile.Path)
+		if err != nil {
+			return err
+		}
+		entry = &cacheEntry{
+			LocaleID:     localeFile.ID,
+			Params:       target.contentParams(localeFile),
+			ETag:         validators.ETag,
+			LastModified: validators.LastModified,
+			Hash:         hash,
+		}
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if entry == nil {
+		delete(cache.Projects[target.ProjectID], localeFile.RelPath())
+		return nil
+	}
+	if cache.Projects[target.ProjectID] == nil {
+		cache.Projects[target.ProjectID] = map[string]*cacheEntry{}
+	}
+	cache.Projects[target.ProjectID][localeFile.RelPath()] = entry
+	return nil
+}
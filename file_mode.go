@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultFileMode os.FileMode = 0644
+	defaultDirMode  os.FileMode = 0755
+)
+
+// fileMode is a permission setting like `file_mode: 0644`. The value is
+// always read as octal number, with or without leading zero.
+type fileMode os.FileMode
+
+func (mode *fileMode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// unmarshalling into a string returns the number as written in the
+	// config, before YAML interprets a leading zero
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || v == 0 || v > 0777 {
+		return fmt.Errorf("invalid mode %q, expected an octal number like 0644", s)
+	}
+	*mode = fileMode(v)
+	return nil
+}
+
+// Writes the content to a temporary file in the directory of path, syncs it
+// and renames it to path, so that readers never see a partially written
+// file. An existing file keeps its mode, new files are created with the
+// given mode.
+func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	err = writeAndSync(f, content, mode)
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func writeAndSync(f *os.File, content []byte, mode os.FileMode) error {
+	_, err := f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	return err
+}
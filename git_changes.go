@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitChanges contains the files of a git work tree that changed since a
+// revision, including uncommitted and untracked files.
+type gitChanges struct {
+	ref   string
+	files map[string]bool
+}
+
+func changedSince(ref string) (*gitChanges, error) {
+	out, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("--changed-since requires a git work tree: %s", err)
+	}
+	root := evalSymlinks(strings.TrimSpace(out))
+
+	changes := &gitChanges{ref: ref, files: map[string]bool{}}
+	for _, args := range [][]string{
+		{"diff", "--name-only", "-z", ref, "--"},
+		{"ls-files", "--others", "--exclude-standard", "-z", "--full-name"},
+	} {
+		out, err := gitIn(root, args...)
+		if err != nil {
+			return nil, fmt.Errorf("could not determine files changed since %q: %s", ref, err)
+		}
+		for _, name := range strings.Split(out, "\x00") {
+			if name != "" {
+				changes.files[filepath.Join(root, filepath.FromSlash(name))] = true
+			}
+		}
+	}
+	return changes, nil
+}
+
+// Filters the locale files that changed since the revision.
+func (changes *gitChanges) filter(localeFiles LocaleFiles) LocaleFiles {
+	changed := LocaleFiles{}
+	for _, localeFile := range localeFiles {
+		if !changes.files[evalSymlinks(localeFile.Path)] {
+			if Debug {
+				fmt.Printf("Skipping %s (unchanged since %s)\n", localeFile.RelPath(), changes.ref)
+			}
+			continue
+		}
+		changed = append(changed, localeFile)
+	}
+	return changed
+}
+
+func git(args ...string) (string, error) {
+	return gitIn("", args...)
+}
+
+func gitIn(dir string, args ...string) (string, error) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// Resolves symbolic links in the path, so that paths reported by git can be
+// compared with the ones of the locale files. Returns the path unchanged if
+// it can't be resolved.
+func evalSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
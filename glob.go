@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// globPattern is a compiled file pattern. Patterns are matched segment by
+// segment: a `**` segment matches any number of directories, `*` and `?`
+// match any sequence of characters or a single character within a segment,
+// `[a-z]` (or `[!a-z]`) matches a character class and `{yml,yaml}` one of
+// the alternatives. Alternatives may not contain path separators.
+// Placeholders like <locale_code> match like `*` and capture the matched
+// value.
+type globPattern struct {
+	pattern  string
+	segments []*globSegment
+}
+
+type globSegment struct {
+	recursive bool
+	re        *regexp.Regexp
+}
+
+const globMetaChars = "*?[{<"
+
+func compileGlob(pattern string) (*globPattern, error) {
+	g := &globPattern{pattern: pattern}
+	for _, token := range splitPathToTokens(pattern) {
+		if token == "**" {
+			g.segments = append(g.segments, &globSegment{recursive: true})
+			continue
+		}
+
+		re, err := regexp.Compile("^" + globToRegexp(token) + "$")
+		if err != nil {
+			return nil, err
+		}
+		g.segments = append(g.segments, &globSegment{re: re})
+	}
+	return g, nil
+}
+
+// Translates a single path segment of a glob pattern into a regular
+// expression. Unbalanced brackets and braces are taken literally.
+func globToRegexp(segment string) string {
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(segment); {
+		if loc := placeholderRegexp.FindStringSubmatchIndex(segment[i:]); loc != nil && loc[0] == 0 {
+			name := placeholderGroupName(segment[i+loc[2]:i+loc[3]], segment[i+loc[4]:i+loc[5]])
+			buf.WriteString("(?P<" + name + ">.+)")
+			i += loc[1]
+			continue
+		}
+
+		switch segment[i] {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '[':
+			if end := classEnd(segment, i); end > 0 {
+				buf.WriteString(classToRegexp(segment[i+1 : end]))
+				i = end + 1
+				continue
+			}
+			buf.WriteString(`\[`)
+		case '{':
+			if end := braceEnd(segment, i); end > 0 {
+				alternatives := []string{}
+				for _, alt := range splitAlternatives(segment[i+1 : end]) {
+					alternatives = append(alternatives, globToRegexp(alt))
+				}
+				buf.WriteString("(?:" + strings.Join(alternatives, "|") + ")")
+				i = end + 1
+				continue
+			}
+			buf.WriteString(`\{`)
+		default:
+			buf.WriteString(regexp.QuoteMeta(segment[i : i+1]))
+		}
+		i++
+	}
+	return buf.String()
+}
+
+// Returns the index of the bracket closing the character class opened at
+// start, or -1. A `]` directly after the opening bracket is part of the class.
+func classEnd(s string, start int) int {
+	i := start + 1
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		i++
+	}
+	if i < len(s) && s[i] == ']' {
+		i++
+	}
+	for ; i < len(s); i++ {
+		if s[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+func classToRegexp(class string) string {
+	buf := bytes.NewBufferString("[")
+	if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+		buf.WriteString("^")
+		class = class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		switch c := class[i]; c {
+		case '\\', '[', ']', '^':
+			buf.WriteString(`\` + string(c))
+		default:
+			buf.WriteString(class[i : i+1])
+		}
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+// Returns the index of the brace closing the one opened at start, or -1.
+func braceEnd(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Splits the content of a brace expression at the commas not nested in
+// another brace expression.
+func splitAlternatives(s string) []string {
+	alternatives := []string{}
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alternatives, s[start:])
+}
+
+// Matches the path against the pattern and returns the values captured by
+// the placeholders.
+func (g *globPattern) match(path string) (map[string]string, bool) {
+	params := map[string]string{}
+	if !matchSegments(g.segments, splitPathToTokens(path), params) {
+		return nil, false
+	}
+	return params, true
+}
+
+func matchSegments(segments []*globSegment, path []string, params map[string]string) bool {
+	if len(segments) == 0 {
+		return len(path) == 0
+	}
+
+	seg := segments[0]
+	if seg.recursive {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(segments[1:], path[i:], params) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+	submatches := seg.re.FindStringSubmatch(path[0])
+	if submatches == nil || !matchSegments(segments[1:], path[1:], params) {
+		return false
+	}
+
+	// captures are only recorded for the alignment that matched completely
+	for i, name := range seg.re.SubexpNames() {
+		if name != "" {
+			params[name] = submatches[i]
+		}
+	}
+	return true
+}
+
+// Reports whether files below the given directory could match the pattern.
+func (g *globPattern) mayContain(dir string) bool {
+	return matchPrefix(g.segments, splitPathToTokens(dir))
+}
+
+func matchPrefix(segments []*globSegment, path []string) bool {
+	switch {
+	case len(path) == 0:
+		return len(segments) > 0
+	case len(segments) == 0:
+		return false
+	case segments[0].recursive:
+		return true
+	case !segments[0].re.MatchString(path[0]):
+		return false
+	default:
+		return matchPrefix(segments[1:], path[1:])
+	}
+}
+
+// Returns the longest leading part of the pattern without any wildcards or
+// placeholders, i.e. the directory (or file) all matches are located in.
+func (g *globPattern) root() string {
+	root, start := "", 0
+	for i := 0; i <= len(g.pattern); i++ {
+		if i < len(g.pattern) && !os.IsPathSeparator(g.pattern[i]) && g.pattern[i] != '/' {
+			continue
+		}
+		if strings.ContainsAny(g.pattern[start:i], globMetaChars) {
+			break
+		}
+		root, start = g.pattern[:i], i+1
+	}
+
+	switch {
+	case root == "" && filepath.IsAbs(g.pattern):
+		return g.pattern[:1]
+	case root == "":
+		return "."
+	default:
+		return filepath.Clean(root)
+	}
+}
+
+// Returns all files matching the pattern. Only directories that could
+// contain matches are traversed, the state directory of the client is
+// skipped.
+func (g *globPattern) files() ([]string, error) {
+	root := g.root()
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	matches := []string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && (info.Name() == stateDir || !g.mayContain(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := g.match(path); ok {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// Reports whether the path matches the glob pattern. Patterns without a path
+// separator are matched against the base name of the path only, so
+// `*.bak.yml` excludes such files everywhere.
+func matchGlob(pattern, path string) bool {
+	if !strings.ContainsAny(pattern, `/\`) {
+		path = filepath.Base(path)
+	} else if filepath.IsAbs(path) != filepath.IsAbs(pattern) {
+		var err error
+		if pattern, err = filepath.Abs(pattern); err != nil {
+			return false
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return false
+		}
+	}
+
+	g, err := compileGlob(pattern)
+	if err != nil {
+		return false
+	}
+	_, matched := g.match(path)
+	return matched
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tt := []struct {
+		pattern string
+		path    string
+		matches bool
+		params  map[string]string
+	}{
+		{"a/*.yml", "a/en.yml", true, nil},
+		{"a/*.yml", "a/b/en.yml", false, nil},
+		{"a/*.yml", "a/en.yml.bak", false, nil},
+		{"a/*.*.yml", "a/devise.en.yml", true, nil},
+		{"a/*.*.yml", "a/en.yml", false, nil},
+		{"*/*/en.yml", "a/b/en.yml", true, nil},
+		{"a/**/en.yml", "a/en.yml", true, nil},
+		{"a/**/en.yml", "a/b/c/en.yml", true, nil},
+		{"a/**/x/**/en.yml", "a/b/x/c/d/en.yml", true, nil},
+		{"a/**/x/**/en.yml", "a/b/c/d/en.yml", false, nil},
+		{"a/??.yml", "a/en.yml", true, nil},
+		{"a/??.yml", "a/eng.yml", false, nil},
+		{"a/[a-f]*.yml", "a/de.yml", true, nil},
+		{"a/[a-f]*.yml", "a/fr.yml", true, nil},
+		{"a/[!a-f]*.yml", "a/fr.yml", false, nil},
+		{"a/[!a-f]*.yml", "a/it.yml", true, nil},
+		{"a/*.{yml,yaml}", "a/en.yaml", true, nil},
+		{"a/*.{yml,yaml}", "a/en.json", false, nil},
+		{"a/{en,de{,-AT}}.yml", "a/de-AT.yml", true, nil},
+		{"a/{en,de{,-AT}}.yml", "a/fr.yml", false, nil},
+		{"a/}{x/][etc??/en.yml", "a/}{x/][etc??/en.yml", true, nil},
+		{"./a/<locale_code>.yml", "a/en.yml", true, map[string]string{"locale_code": "en"}},
+		{"a/<locale_name>.<locale_code>", "a/play.en", true, map[string]string{"locale_name": "play", "locale_code": "en"}},
+		{"a/<locale_name>.json", "a/foo.bar.json", true, map[string]string{"locale_name": "foo.bar"}},
+		{"**/<tag>/<locale_code>.{yml,yaml}", "x/y/app/en.yaml", true, map[string]string{"tag": "app", "locale_code": "en"}},
+		{
+			"packages/*/locales/**/<locale_code>.json",
+			"packages/checkout/locales/web/de-AT.json",
+			true,
+			map[string]string{"locale_code": "de-AT"},
+		},
+	}
+
+	for _, tti := range tt {
+		g, err := compileGlob(tti.pattern)
+		if err != nil {
+			t.Errorf("%s: didn't expect an error, got: %s", tti.pattern, err)
+			continue
+		}
+
+		params, matches := g.match(tti.path)
+		if matches != tti.matches {
+			t.Errorf("%s: expected match of %q to be %t, got %t", tti.pattern, tti.path, tti.matches, matches)
+			continue
+		}
+		for k, v := range tti.params {
+			if params[k] != v {
+				t.Errorf("%s: expected %s to be %q, got %q", tti.pattern, k, v, params[k])
+			}
+		}
+	}
+}
+
+func TestGlobRoot(t *testing.T) {
+	tt := []struct {
+		pattern string
+		root    string
+	}{
+		{"*.yml", "."},
+		{"./config/locales/**/*.yml", "config/locales"},
+		{"config/<locale_code>/app.yml", "config"},
+		{"config/{a,b}/app.yml", "config"},
+		{"config/locales/en.yml", "config/locales/en.yml"},
+		{"/*.yml", "/"},
+		{"/srv/app/*.yml", "/srv/app"},
+	}
+
+	for _, tti := range tt {
+		g, err := compileGlob(tti.pattern)
+		if err != nil {
+			t.Fatalf("%s: didn't expect an error, got: %s", tti.pattern, err)
+		}
+		if got := g.root(); got != tti.root {
+			t.Errorf("%s: expected root %q, got %q", tti.pattern, tti.root, got)
+		}
+	}
+}
+
+func TestGlobFiles(t *testing.T) {
+	d := setupFiles(t,
+		"packages/checkout/locales/web/en.json",
+		"packages/checkout/locales/de.json",
+		"packages/checkout/src/en.json",
+		"packages/search/locales/mobile/fr.json",
+		"packages/search/locales/fr.yml",
+		"locales/en.yml",
+		"locales/en.yaml",
+		"locales/en.json",
+		".phraseapp/cache.yml",
+		".phraseapp/snapshots/20161017-120000/files/0-en.yml",
+	)
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	tt := []struct {
+		pattern string
+		files   []string
+	}{
+		{"packages/*/locales/**/<locale_code>.json", []string{
+			"packages/checkout/locales/de.json",
+			"packages/checkout/locales/web/en.json",
+			"packages/search/locales/mobile/fr.json",
+		}},
+		{"./locales/*.{yml,yaml}", []string{"locales/en.yaml", "locales/en.yml"}},
+		{"**/<locale_code>.yml", []string{"locales/en.yml", "packages/search/locales/fr.yml"}},
+		{"./**/<locale_code>.yml", []string{"locales/en.yml", "packages/search/locales/fr.yml"}},
+		{"missing/*.yml", []string{}},
+	}
+
+	for _, tti := range tt {
+		g, err := compileGlob(tti.pattern)
+		if err != nil {
+			t.Fatalf("%s: didn't expect an error, got: %s", tti.pattern, err)
+		}
+
+		files, err := g.files()
+		if err != nil {
+			t.Errorf("%s: didn't expect an error, got: %s", tti.pattern, err)
+			continue
+		}
+		sort.Strings(files)
+
+		if strings.Join(files, " ") != strings.Join(tti.files, " ") {
+			t.Errorf("%s: expected files %v, got %v", tti.pattern, tti.files, files)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// Hooks are shell commands run before and after push and pull, configured
+// in the top-level `hooks` block. The per-file hooks run for every uploaded
+// or downloaded file and get the locale file data as environment variables
+// (see localeFileEnv).
+type Hooks struct {
+	BeforePush     hookCommands
+	AfterPush      hookCommands
+	BeforePushFile hookCommands
+	AfterPushFile  hookCommands
+	BeforePull     hookCommands
+	AfterPull      hookCommands
+	BeforePullFile hookCommands
+	AfterPullFile  hookCommands
+}
+
+func (hooks *Hooks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := map[string]*[]byte{}
+	fields := map[string]*hookCommands{
+		"before_push":      &hooks.BeforePush,
+		"after_push":       &hooks.AfterPush,
+		"before_push_file": &hooks.BeforePushFile,
+		"after_push_file":  &hooks.AfterPushFile,
+		"before_pull":      &hooks.BeforePull,
+		"after_pull":       &hooks.AfterPull,
+		"before_pull_file": &hooks.BeforePullFile,
+		"after_pull_file":  &hooks.AfterPullFile,
+	}
+
+	keysToField := map[string]interface{}{}
+	for key := range fields {
+		raw[key] = new([]byte)
+		keysToField[key] = raw[key]
+	}
+	if err := phraseapp.ParseYAMLToMap(unmarshal, keysToField); err != nil {
+		return err
+	}
+
+	for key, field := range fields {
+		if *raw[key] == nil {
+			continue
+		}
+		if err := yaml.Unmarshal(*raw[key], field); err != nil {
+			return fmt.Errorf("configuration key \"hooks.%s\" must be a command or a list of commands", key)
+		}
+	}
+	return nil
+}
+
+// hookCommands is a single command or a list of commands.
+type hookCommands []string
+
+func (commands *hookCommands) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var command string
+	if err := unmarshal(&command); err == nil {
+		*commands = hookCommands{command}
+		return nil
+	}
+
+	list := []string{}
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*commands = hookCommands(list)
+	return nil
+}
+
+func HooksFromConfig(cfg *clientConfig) (*Hooks, error) {
+	hooks := new(Hooks)
+	if len(cfg.Hooks) == 0 {
+		return hooks, nil
+	}
+
+	if err := yaml.Unmarshal(cfg.Hooks, hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// hookError is returned if a hook command fails. The client exits with the
+// exit status of the command.
+type hookError struct {
+	hook    string
+	command string
+	status  int
+}
+
+func (e *hookError) Error() string {
+	return fmt.Sprintf("%s hook %q failed with exit status %d", e.hook, e.command, e.status)
+}
+
+// Runs the commands of a hook one after another and stops at the first
+// failing one. The environment of the client is extended by env. The output
+// of the commands is written to out, or to the terminal if out is nil.
+func (commands hookCommands) run(hook string, env []string, out io.Writer) error {
+	for _, command := range commands {
+		if command == "" {
+			continue
+		}
+		cmd := shellCommand(command)
+		cmd.Env = append(os.Environ(), env...)
+		if out != nil {
+			cmd.Stdout, cmd.Stderr = out, out
+		} else {
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		}
+
+		if Debug {
+			fmt.Fprintf(cmd.Stderr, "Running %s hook: %s\n", hook, command)
+		}
+
+		err := cmd.Run()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			status := 1
+			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				status = ws.ExitStatus()
+			}
+			return &hookError{hook: hook, command: command, status: status}
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %q could not be run: %s", hook, command, err)
+		}
+	}
+	return nil
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// Returns the data of the locale file as environment variables for the
+// per-file hooks.
+func localeFileEnv(localeFile *LocaleFile) []string {
+	return []string{
+		"PHRASEAPP_FILE=" + localeFile.Path,
+		"PHRASEAPP_LOCALE_ID=" + localeFile.ID,
+		"PHRASEAPP_LOCALE_CODE=" + localeFile.Code,
+		"PHRASEAPP_LOCALE_NAME=" + localeFile.Name,
+		"PHRASEAPP_TAG=" + localeFile.Tag,
+		"PHRASEAPP_FILE_FORMAT=" + localeFile.FileFormat,
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHooksFromConfig(t *testing.T) {
+	cfg := &clientConfig{Hooks: []byte(`
+before_push: make strings
+after_pull:
+- prettier --write locales
+- xmllint --noout res/values/strings.xml
+`)}
+
+	hooks, err := HooksFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	if strings.Join(hooks.BeforePush, "|") != "make strings" {
+		t.Errorf("unexpected before_push hook %v", hooks.BeforePush)
+	}
+	if strings.Join(hooks.AfterPull, "|") != "prettier --write locales|xmllint --noout res/values/strings.xml" {
+		t.Errorf("unexpected after_pull hook %v", hooks.AfterPull)
+	}
+	if len(hooks.AfterPush) != 0 {
+		t.Errorf("expected no after_push hook, got %v", hooks.AfterPush)
+	}
+
+	cfg.Hooks = []byte("after_puhs: make\n")
+	if _, err := HooksFromConfig(cfg); err == nil || !strings.Contains(err.Error(), `"after_puhs" unknown`) {
+		t.Errorf("expected an unknown key error, got: %v", err)
+	}
+}
+
+func TestHookCommandsRun(t *testing.T) {
+	d, err := ioutil.TempDir("", "phrase-hooks-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	out := filepath.Join(d, "out")
+
+	localeFile := &LocaleFile{Path: "/tmp/locales/de.yml", Code: "de", Name: "german", Tag: "web"}
+	commands := hookCommands{
+		`echo "$PHRASEAPP_FILE $PHRASEAPP_LOCALE_CODE $PHRASEAPP_LOCALE_NAME $PHRASEAPP_TAG" > ` + out,
+	}
+	if err := commands.run("after_pull_file", localeFileEnv(localeFile), nil); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "/tmp/locales/de.yml de german web\n" {
+		t.Errorf("unexpected hook environment %q", content)
+	}
+
+	buf := new(bytes.Buffer)
+	commands = hookCommands{"echo $PHRASEAPP_LOCALE_CODE", "echo failed >&2"}
+	if err := commands.run("after_pull_file", localeFileEnv(localeFile), buf); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if buf.String() != "de\nfailed\n" {
+		t.Errorf("expected the hook output to be captured, got %q", buf.String())
+	}
+
+	commands = hookCommands{"exit 3", "touch " + out + ".not-run"}
+	err = commands.run("before_push", nil, nil)
+	herr, ok := err.(*hookError)
+	if !ok || herr.status != 3 {
+		t.Errorf("expected hook error with exit status 3, got: %v", err)
+	}
+	if _, err := os.Stat(out + ".not-run"); !os.IsNotExist(err) {
+		t.Errorf("expected the commands after the failing one not to run")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// LocaleDefaults are the attributes of locales created by push. They are
+// configured in `locale_defaults` blocks of the push config or of a source:
+//
+//	locale_defaults:
+//	  source_locale_id: abcd1234   # all created locales
+//	  ar:                          # locales with code ar only
+//	    name: Arabic
+//
+// Locales of right-to-left languages are created with rtl set, unless the
+// defaults say otherwise.
+type LocaleDefaults struct {
+	All    *phraseapp.LocaleParams
+	ByCode map[string]*phraseapp.LocaleParams
+}
+
+func parseLocaleDefaults(key string, m map[string]interface{}) (*LocaleDefaults, error) {
+	defaults := &LocaleDefaults{
+		All:    new(phraseapp.LocaleParams),
+		ByCode: map[string]*phraseapp.LocaleParams{},
+	}
+
+	all := map[string]interface{}{}
+	for k, v := range m {
+		if _, isMap := v.(map[interface{}]interface{}); !isMap {
+			all[k] = v
+			continue
+		}
+
+		raw, err := phraseapp.ValidateIsRawMap(key+"."+k, v)
+		if err != nil {
+			return nil, err
+		}
+		params := new(phraseapp.LocaleParams)
+		if err := params.ApplyValuesFromMap(raw); err != nil {
+			return nil, err
+		}
+		defaults.ByCode[k] = params
+	}
+
+	if err := defaults.All.ApplyValuesFromMap(all); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// Returns the defaults with the ones of other taking precedence.
+func (defaults *LocaleDefaults) merge(other *LocaleDefaults) *LocaleDefaults {
+	merged := &LocaleDefaults{
+		All:    new(phraseapp.LocaleParams),
+		ByCode: map[string]*phraseapp.LocaleParams{},
+	}
+	for _, d := range []*LocaleDefaults{defaults, other} {
+		if d == nil {
+			continue
+		}
+		mergeLocaleParams(merged.All, d.All)
+		for code, params := range d.ByCode {
+			if merged.ByCode[code] == nil {
+				merged.ByCode[code] = new(phraseapp.LocaleParams)
+			}
+			mergeLocaleParams(merged.ByCode[code], params)
+		}
+	}
+	return merged
+}
+
+// Applies the defaults to the parameters of a locale to be created. The
+// name and code derived from the locale file take precedence over the
+// defaults for all locales, but not over the ones for the locale code.
+func (defaults *LocaleDefaults) apply(params *phraseapp.LocaleParams, code string) {
+	base := new(phraseapp.LocaleParams)
+	if isRTLLanguage(code) {
+		rtl := true
+		base.Rtl = &rtl
+	}
+
+	if defaults != nil {
+		mergeLocaleParams(base, defaults.All)
+	}
+	mergeLocaleParams(base, params)
+	if defaults != nil {
+		mergeLocaleParams(base, defaults.ByCode[code])
+	}
+	*params = *base
+}
+
+// Sets all fields of dst that are set in src.
+func mergeLocaleParams(dst, src *phraseapp.LocaleParams) {
+	if src == nil {
+		return
+	}
+	if src.Code != nil {
+		dst.Code = src.Code
+	}
+	if src.Default != nil {
+		dst.Default = src.Default
+	}
+	if src.Main != nil {
+		dst.Main = src.Main
+	}
+	if src.Name != nil {
+		dst.Name = src.Name
+	}
+	if src.Rtl != nil {
+		dst.Rtl = src.Rtl
+	}
+	if src.SourceLocaleID != nil {
+		dst.SourceLocaleID = src.SourceLocaleID
+	}
+}
+
+var rtlLanguages = map[string]bool{
+	"ar": true, "arc": true, "ckb": true, "dv": true, "fa": true, "he": true, "iw": true,
+	"ks": true, "ps": true, "sd": true, "ug": true, "ur": true, "yi": true,
+}
+
+func isRTLLanguage(code string) bool {
+	language, _ := splitLocaleCode(code)
+	return rtlLanguages[strings.ToLower(language)]
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// LocaleMapping maps local locale codes, as they appear in file paths (like
+// `pt_BR` or `values-es-rUS`), to remote locales referenced by ID, name or
+// code. It is configured in the top-level `locale_mapping` block and takes
+// precedence over the matching heuristics of push and pull.
+type LocaleMapping map[string]string
+
+// Returns the remote locale reference for the locale file, looked up by the
+// code and then by the name extracted from the file path.
+func (mapping LocaleMapping) lookup(localeFile *LocaleFile) (string, bool) {
+	for _, local := range []string{localeFile.Code, localeFile.Name} {
+		if local == "" {
+			continue
+		}
+		if ref, found := mapping[local]; found {
+			return ref, true
+		}
+	}
+	return "", false
+}
+
+// Returns the local codes mapped to each remote locale of the project, keyed
+// by locale ID and in sorted order. As the mapping is shared by all targets,
+// references to locales of other projects are ignored, ambiguous references
+// fail like they do on push.
+func (mapping LocaleMapping) localCodes(remoteLocales []*phraseapp.Locale) (map[string][]string, error) {
+	locals := make([]string, 0, len(mapping))
+	for local := range mapping {
+		locals = append(locals, local)
+	}
+	sort.Strings(locals)
+
+	codes := map[string][]string{}
+	for _, local := range locals {
+		candidates := matchMappedLocale(remoteLocales, mapping[local])
+		switch len(candidates) {
+		case 0:
+			continue
+		case 1:
+			codes[candidates[0].ID] = append(codes[candidates[0].ID], local)
+		default:
+			return nil, ambiguousLocaleError(fmt.Sprintf("locale %q", mapping[local]), candidates)
+		}
+	}
+	return codes, nil
+}
+
+// Resolves a mapping reference to a remote locale. IDs take precedence over
+// names, names over codes.
+func findMappedLocale(remoteLocales []*phraseapp.Locale, ref string) (*phraseapp.Locale, error) {
+	candidates := matchMappedLocale(remoteLocales, ref)
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("locale_mapping refers to locale %q, which does not exist in the project", ref)
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, ambiguousLocaleError(fmt.Sprintf("locale %q", ref), candidates)
+	}
+}
+
+// Returns the remote locales matching a mapping reference by ID, or if there
+// are none by name, or else by code.
+func matchMappedLocale(remoteLocales []*phraseapp.Locale, ref string) []*phraseapp.Locale {
+	matchers := []func(*phraseapp.Locale) bool{
+		func(l *phraseapp.Locale) bool { return l.ID == ref },
+		func(l *phraseapp.Locale) bool { return l.Name == ref },
+		func(l *phraseapp.Locale) bool { return l.Code == ref },
+	}
+
+	for _, matches := range matchers {
+		candidates := []*phraseapp.Locale{}
+		for _, l := range remoteLocales {
+			if matches(l) {
+				candidates = append(candidates, l)
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates
+		}
+	}
+	return nil
+}
+
+func ambiguousLocaleError(subject string, candidates []*phraseapp.Locale) error {
+	descriptions := make([]string, len(candidates))
+	for i, l := range candidates {
+		descriptions[i] = fmt.Sprintf("\t%s (id: %s, code: %s)", l.Name, l.ID, l.Code)
+	}
+	return fmt.Errorf(
+		"%s matches several remote locales:\n%s\nMap it to a locale ID in locale_mapping to pick one of them.",
+		subject, strings.Join(descriptions, "\n"),
+	)
+}
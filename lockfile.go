@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+const lockfileName = ".phraseapp.lock"
+
+// Lockfile records every locale file that was pushed successfully, so that
+// following pushes can skip files that didn't change since. Entries are
+// grouped by project and keyed by the path relative to the working directory.
+// Files pushed without waiting for the import are recorded with the ID of
+// the upload, and only skipped once the upload was processed successfully.
+type Lockfile struct {
+	path string
+
+	mutex    sync.Mutex
+	Projects map[string]map[string]*lockEntry
+}
+
+type lockEntry struct {
+	Hash     string `yaml:"hash"`
+	LocaleID string `yaml:"locale_id,omitempty"`
+	Params   string `yaml:"params"`
+	UploadID string `yaml:"upload_id,omitempty"`
+}
+
+func loadLockfile(path string) (*Lockfile, error) {
+	lock := &Lockfile{path: path, Projects: map[string]map[string]*lockEntry{}}
+
+	content, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return lock, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, &lock.Projects); err != nil {
+		return nil, fmt.Errorf("%s is invalid: %s", path, err)
+	}
+	if lock.Projects == nil {
+		lock.Projects = map[string]map[string]*lockEntry{}
+	}
+	return lock, nil
+}
+
+func (lock *Lockfile) save() error {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	content, err := yaml.Marshal(lock.Projects)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(lock.path, content, 0644)
+}
+
+// Builds the entry describing the current state of the locale file.
+func (lock *Lockfile) entry(source *Source, localeFile *LocaleFile) (*lockEntry, error) {
+	hash, err := fileHash(localeFile.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := source.uploadParams(localeFile)
+	return &lockEntry{
+		Hash:     hash,
+		LocaleID: stringValue(params.LocaleID),
+		Params:   formatUploadParams(params),
+	}, nil
+}
+
+// Returns whether the locale file was already pushed with the same content
+// and upload parameters.
+func (lock *Lockfile) unchanged(client *phraseapp.Client, source *Source, localeFile *LocaleFile) bool {
+	lock.mutex.Lock()
+	recorded, found := lock.Projects[source.ProjectID][localeFile.RelPath()]
+	lock.mutex.Unlock()
+	if !found {
+		return false
+	}
+
+	current, err := lock.entry(source, localeFile)
+	if err != nil {
+		return false
+	}
+	if current.Hash != recorded.Hash || current.LocaleID != recorded.LocaleID || current.Params != recorded.Params {
+		return false
+	}
+	return recorded.UploadID == "" || lock.confirm(client, source.ProjectID, recorded)
+}
+
+// Reports whether the upload of the entry was processed successfully, and
+// if so, records that it doesn't need to be checked anymore. Uploads that
+// failed or are still processing are pushed again.
+func (lock *Lockfile) confirm(client *phraseapp.Client, projectID string, entry *lockEntry) bool {
+	var upload *phraseapp.Upload
+	err := rateLimit.do(func() (err error) {
+		upload, err = client.UploadShow(projectID, entry.UploadID)
+		return err
+	})
+	if err != nil || upload.State != uploadStateSuccess {
+		return false
+	}
+
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	entry.UploadID = ""
+	return true
+}
+
+// Filters all locale files that didn't change since the last push.
+func (lock *Lockfile) changed(client *phraseapp.Client, source *Source, localeFiles LocaleFiles) LocaleFiles {
+	changed := LocaleFiles{}
+	for _, localeFile := range localeFiles {
+		if lock.unchanged(client, source, localeFile) {
+			fmt.Println("Skipping", localeFile.RelPath(), "(unchanged since last push)")
+			continue
+		}
+		changed = append(changed, localeFile)
+	}
+	return changed
+}
+
+// Records the pushed locale file. The uploadID is set if the upload wasn't
+// awaited, so that it is checked before the file is skipped.
+func (lock *Lockfile) record(source *Source, localeFile *LocaleFile, uploadID string) error {
+	entry, err := lock.entry(source, localeFile)
+	if err != nil {
+		return err
+	}
+	entry.UploadID = uploadID
+
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	if lock.Projects[source.ProjectID] == nil {
+		lock.Projects[source.ProjectID] = map[string]*lockEntry{}
+	}
+	lock.Projects[source.ProjectID][localeFile.RelPath()] = entry
+	return nil
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -28,13 +28,13 @@ func Run() {
 	phraseapp.ClientVersion = PHRASEAPP_CLIENT_VERSION
 	ValidateVersion()
 
-	cfg, err := phraseapp.ReadConfig()
+	cfg, clientCfg, err := readConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(2)
 	}
 
-	r, err := router(cfg)
+	r, err := router(cfg, clientCfg)
 	if err != nil {
 		printErr(err)
 		os.Exit(3)
@@ -47,6 +47,9 @@ func Run() {
 		os.Exit(0)
 	default:
 		printErr(err)
+		if herr, ok := err.(*hookError); ok {
+			os.Exit(herr.status)
+		}
 		os.Exit(1)
 	}
 }
@@ -69,7 +69,7 @@ func captureStderr(f func() error) (string, error) {
 }
 
 func runWithCfg(cfg *phraseapp.Config, cmd string, additionalOpts ...string) (string, error) {
-	r, err := router(cfg)
+	r, err := router(cfg, new(clientConfig))
 	if err != nil {
 		return "", err
 	}
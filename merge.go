@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// merger deep-merges the downloaded content of a file format into the
+// content of the local file.
+type merger func(local, remote []byte, m *merge) ([]byte, error)
+
+var mergers = map[string]merger{
+	"yml":               mergeYAML,
+	"yml_symfony":       mergeYAML,
+	"yml_symfony2":      mergeYAML,
+	"simple_json":       mergeJSON,
+	"nested_json":       mergeJSON,
+	"react_simple_json": mergeJSON,
+	"react_nested_json": mergeJSON,
+	"i18next":           mergeJSON,
+	"go_i18n":           mergeJSON,
+	"angular_translate": mergeJSON,
+	"properties":        mergeProperties,
+	"strings":           mergeStrings,
+}
+
+// merge collects the outcome of merging a downloaded file into a local file.
+// Keys only found in the local file are kept. Keys found in both take the
+// remote value, unless preferLocal is set.
+type merge struct {
+	preferLocal bool
+
+	// kept are the local-only keys, nested keys are joined by dots.
+	kept []string
+
+	// changed is set if the merged content differs from the remote one.
+	changed bool
+}
+
+// Checks that files of the format can be merged.
+func checkMerge(format string) error {
+	if _, found := mergers[format]; !found {
+		return fmt.Errorf("merge is not supported for the file format %q", format)
+	}
+	return nil
+}
+
+// Merges the downloaded content of a file of the format into the local
+// content. The remote content is returned as it is if there is nothing to
+// merge.
+func mergeContent(format string, local, remote []byte, preferLocal bool) ([]byte, []string, error) {
+	m := &merge{preferLocal: preferLocal}
+	if len(bytes.TrimSpace(local)) == 0 {
+		return remote, nil, nil
+	}
+
+	hasBOM := bytes.HasPrefix(remote, utf8BOM)
+	merged, err := mergers[format](bytes.TrimPrefix(local, utf8BOM), bytes.TrimPrefix(remote, utf8BOM), m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not merge %s content: %s", format, err)
+	}
+	if !m.changed {
+		return remote, nil, nil
+	}
+	if hasBOM {
+		merged = append(append([]byte{}, utf8BOM...), merged...)
+	}
+	return merged, m.kept, nil
+}
+
+// Merges the mapping nodes of YAML files. Nodes added from the local file
+// are shifted to the indentation of the remote file, sequences are merged
+// as a whole.
+func mergeYAML(local, remote []byte, m *merge) ([]byte, error) {
+	localRoot, _ := parseYAML(withTrailingNewline(local))
+	remoteRoot, pending := parseYAML(withTrailingNewline(remote))
+	m.mergeYAMLNodes(localRoot, remoteRoot, "")
+
+	buf := new(bytes.Buffer)
+	remoteRoot.write(buf, false, 0, 0)
+	for _, line := range pending {
+		buf.WriteString(line)
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *merge) mergeYAMLNodes(local, remote *yamlNode, prefix string) {
+	if !local.sortable || !remote.sortable {
+		return
+	}
+
+	index := map[string]int{}
+	for i, child := range remote.children {
+		index[child.key] = i
+	}
+	for _, child := range local.children {
+		key := prefix + child.key
+		i, found := index[child.key]
+		switch {
+		case !found:
+			column := remote.indent + child.indent - local.indent
+			if len(remote.children) > 0 {
+				column = remote.children[0].indent
+			}
+			child.shift(column - child.indent)
+			remote.children = append(remote.children, child)
+			m.kept = append(m.kept, key)
+			m.changed = true
+		case child.isBlock && remote.children[i].isBlock:
+			m.mergeYAMLNodes(child, remote.children[i], key+".")
+		case m.preferLocal:
+			child.shift(remote.children[i].indent - child.indent)
+			remote.children[i] = child
+			m.changed = true
+		}
+	}
+}
+
+// Shifts the lines of the node and its children by the number of columns.
+func (node *yamlNode) shift(columns int) {
+	for i, line := range node.lines {
+		node.lines[i] = shiftLine(line, columns)
+	}
+	node.indent += columns
+	for _, child := range node.children {
+		child.shift(columns)
+	}
+}
+
+func withTrailingNewline(content []byte) []byte {
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		return append(append([]byte{}, content...), '\n')
+	}
+	return content
+}
+
+// Merges the objects of JSON files. Arrays are merged as a whole. The merged
+// file is written with the indentation of the remote file.
+func mergeJSON(local, remote []byte, m *merge) ([]byte, error) {
+	localValue, err := parseJSON(local)
+	if err != nil {
+		return nil, err
+	}
+	remoteValue, err := parseJSON(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	localObj, isObj := localValue.(*jsonObject)
+	remoteObj, isRemoteObj := remoteValue.(*jsonObject)
+	if !isObj || !isRemoteObj {
+		return nil, fmt.Errorf("the files must contain a JSON object")
+	}
+	m.mergeJSONObjects(localObj, remoteObj, "")
+
+	buf := new(bytes.Buffer)
+	writeJSON(buf, remoteObj, false, jsonIndent(remote), "")
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func (m *merge) mergeJSONObjects(local, remote *jsonObject, prefix string) {
+	index := map[string]int{}
+	for i, key := range remote.keys {
+		index[key] = i
+	}
+	for i, key := range local.keys {
+		j, found := index[key]
+		if !found {
+			remote.keys = append(remote.keys, key)
+			remote.values = append(remote.values, local.values[i])
+			m.kept = append(m.kept, prefix+key)
+			m.changed = true
+			continue
+		}
+
+		localObj, isObj := local.values[i].(*jsonObject)
+		remoteObj, isRemoteObj := remote.values[j].(*jsonObject)
+		switch {
+		case isObj && isRemoteObj:
+			m.mergeJSONObjects(localObj, remoteObj, prefix+key+".")
+		case m.preferLocal:
+			remote.values[j] = local.values[i]
+			m.changed = true
+		}
+	}
+}
+
+// Returns the indentation of the first indented line of a JSON file.
+func jsonIndent(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n")[1:] {
+		if indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]; indent != "" {
+			return indent
+		}
+	}
+	return "  "
+}
+
+func mergeProperties(local, remote []byte, m *merge) ([]byte, error) {
+	localEntries, _ := propertiesEntries(local)
+	remoteEntries, trailer := propertiesEntries(remote)
+	return m.mergeEntries(localEntries, remoteEntries, trailer), nil
+}
+
+func mergeStrings(local, remote []byte, m *merge) ([]byte, error) {
+	localEntries, _, err := stringsEntries(local)
+	if err != nil {
+		return nil, err
+	}
+	remoteEntries, trailer, err := stringsEntries(remote)
+	if err != nil {
+		return nil, err
+	}
+	return m.mergeEntries(localEntries, remoteEntries, trailer), nil
+}
+
+// Merges the entries of line based file formats. Local-only entries are
+// added after the remote ones, together with their comments.
+func (m *merge) mergeEntries(local, remote []sortableEntry, trailer string) []byte {
+	index := map[string]int{}
+	for i, entry := range remote {
+		index[entry.key] = i
+	}
+	for _, entry := range local {
+		i, found := index[entry.key]
+		switch {
+		case entry.key == "":
+		case !found:
+			remote = append(remote, entry)
+			m.kept = append(m.kept, entry.key)
+			m.changed = true
+		case m.preferLocal:
+			remote[i].text = entry.text
+			m.changed = true
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	for _, entry := range remote {
+		buf.WriteString(entry.text)
+	}
+	buf.WriteString(trailer)
+	return buf.Bytes()
+}
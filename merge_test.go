@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeContent(t *testing.T) {
+	for _, tc := range []struct {
+		format      string
+		preferLocal bool
+		local       string
+		remote      string
+		expected    string
+		kept        []string
+	}{
+		{
+			format:   "yml",
+			local:    "en:\n    a: local\n    # new\n    n:\n        x: 1\n    l:\n    - 1\n",
+			remote:   "en:\n  a: remote\n  l:\n  - 2\n  b: 'y'\n",
+			expected: "en:\n  a: remote\n  l:\n  - 2\n  b: 'y'\n  # new\n  n:\n      x: 1\n",
+			kept:     []string{"en.n"},
+		},
+		{
+			format:      "yml",
+			preferLocal: true,
+			local:       "en:\n  a: local\n  s:\n    b: local\n",
+			remote:      "en:\n  a: remote\n  s:\n    b: remote\n    c: remote\n",
+			expected:    "en:\n  a: local\n  s:\n    b: local\n    c: remote\n",
+		},
+		{
+			format:   "nested_json",
+			local:    `{"a": "local", "s": {"n": [1], "b": "local"}}`,
+			remote:   "{\n\t\"s\": {\n\t\t\"b\": \"remote\"\n\t},\n\t\"a\": \"remote\"\n}\n",
+			expected: "{\n\t\"s\": {\n\t\t\"b\": \"remote\",\n\t\t\"n\": [\n\t\t\t1\n\t\t]\n\t},\n\t\"a\": \"remote\"\n}\n",
+			kept:     []string{"s.n"},
+		},
+		{
+			format:      "simple_json",
+			preferLocal: true,
+			local:       `{"a": "local", "b": "local"}`,
+			remote:      "{\"a\": \"remote\"}",
+			expected:    "{\n  \"a\": \"local\",\n  \"b\": \"local\"\n}\n",
+			kept:        []string{"b"},
+		},
+		{
+			format:   "properties",
+			local:    "a=local\n# about n\nn=new\n",
+			remote:   "a=remote\nb=remote\n# trailing\n",
+			expected: "a=remote\nb=remote\n# about n\nn=new\n# trailing\n",
+			kept:     []string{"n"},
+		},
+		{
+			format:      "strings",
+			preferLocal: true,
+			local:       "\"a\" = \"local\";\n\"n\" = \"new\";\n",
+			remote:      "\"a\" = \"remote\";\n\"b\" = \"remote\";",
+			expected:    "\"a\" = \"local\";\n\"b\" = \"remote\";\n\"n\" = \"new\";\n",
+			kept:        []string{"n"},
+		},
+		{
+			format:   "yml",
+			local:    "en:\n  a: local\n",
+			remote:   "en:\n  a: remote",
+			expected: "en:\n  a: remote",
+		},
+	} {
+		out, kept, err := mergeContent(tc.format, []byte(tc.local), []byte(tc.remote), tc.preferLocal)
+		if err != nil {
+			t.Errorf("%s: didn't expect an error, got: %s", tc.format, err)
+			continue
+		}
+		if string(out) != tc.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tc.format, tc.expected, out)
+		}
+		if !reflect.DeepEqual(kept, tc.kept) {
+			t.Errorf("%s: expected kept keys %v, got %v", tc.format, tc.kept, kept)
+		}
+	}
+}
+
+func TestMergeUnsupportedFormat(t *testing.T) {
+	target := &Target{File: "./locales/<locale_code>.xml", FileFormat: "xml", Merge: true}
+	err := target.CheckPreconditions()
+	if err == nil || err.Error() != `merge is not supported for the file format "xml"` {
+		t.Errorf("expected an error about the unsupported format, got: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Placeholders in file patterns may be followed by modifiers transforming
+// their value, e.g. `<locale_code|underscore>` is `pt_BR` for the locale
+// code `pt-BR`. `<language>` and `<region>` are the parts of the locale code.
+// Modifiers are applied from left to right when building paths and reverted
+// when extracting values from paths, so a pattern round-trips.
+var placeholderRegexp = regexp.MustCompile(`<(locale_name|tag|locale_code|language|region)((?:\|[a-z]+)*)>`)
+
+type placeholderModifier struct {
+	apply  func(string) string
+	revert func(string) string
+}
+
+var placeholderModifiers = map[string]placeholderModifier{
+	"underscore": {
+		apply:  func(v string) string { return strings.Replace(v, "-", "_", -1) },
+		revert: func(v string) string { return strings.Replace(v, "_", "-", -1) },
+	},
+	"android": {apply: androidLocaleCode, revert: fromAndroidLocaleCode},
+	"lower":   {apply: strings.ToLower, revert: canonicalLocaleCode},
+}
+
+// Checks that all modifiers used in the pattern are known.
+func validatePlaceholders(pattern string) error {
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(pattern, -1) {
+		for _, modifier := range placeholderModifierNames(m[2]) {
+			if _, found := placeholderModifiers[modifier]; !found {
+				return fmt.Errorf("unknown modifier %q in placeholder %s", modifier, m[0])
+			}
+		}
+	}
+	return nil
+}
+
+func placeholderModifierNames(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(s, "|"), "|")
+}
+
+// Returns the regexp group name for a placeholder. The modifiers are encoded
+// in the name, as `|` is not allowed in group names.
+func placeholderGroupName(name, modifiers string) string {
+	return strings.Replace(name+modifiers, "|", "__", -1)
+}
+
+// Reports whether the pattern contains a placeholder derived from the locale
+// code.
+func usesLocaleCode(pattern string) bool {
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(pattern, -1) {
+		if m[1] != "locale_name" && m[1] != "tag" {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the pattern contains the placeholder, with or without
+// modifiers.
+func usesPlaceholder(pattern, name string) bool {
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(pattern, -1) {
+		if m[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Replaces all placeholders in the pattern with the values of the locale file.
+func replacePlaceholders(pattern string, localeFile *LocaleFile) string {
+	return placeholderRegexp.ReplaceAllStringFunc(pattern, func(s string) string {
+		m := placeholderRegexp.FindStringSubmatch(s)
+
+		var value string
+		switch m[1] {
+		case "locale_name":
+			value = localeFile.Name
+		case "locale_code":
+			value = localeFile.Code
+		case "tag":
+			value = localeFile.Tag
+		case "language":
+			value, _ = splitLocaleCode(localeFile.Code)
+		case "region":
+			_, value = splitLocaleCode(localeFile.Code)
+		}
+
+		for _, modifier := range placeholderModifierNames(m[2]) {
+			if mod, found := placeholderModifiers[modifier]; found {
+				value = mod.apply(value)
+			}
+		}
+		return value
+	})
+}
+
+// Sets the fields of the locale file from values extracted from a path,
+// keyed by group name (see placeholderGroupName).
+func setPlaceholderValues(localeFile *LocaleFile, values map[string]string) {
+	var language, region string
+	for group, value := range values {
+		parts := strings.Split(group, "__")
+		for i := len(parts) - 1; i > 0; i-- {
+			if mod, found := placeholderModifiers[parts[i]]; found {
+				value = mod.revert(value)
+			}
+		}
+
+		switch parts[0] {
+		case "locale_code":
+			localeFile.Code = value
+		case "locale_name":
+			localeFile.Name = value
+		case "tag":
+			localeFile.Tag = value
+		case "language":
+			language = value
+		case "region":
+			region = value
+		}
+	}
+
+	if localeFile.Code == "" && language != "" {
+		localeFile.Code = language
+		if region != "" {
+			localeFile.Code += "-" + region
+		}
+	}
+}
+
+var localeRegionRegexp = regexp.MustCompile(`^([A-Za-z]{2}|[0-9]{3})$`)
+
+// Splits a locale code like `pt-BR`, `pt_BR` or `zh-Hant-TW` into language
+// and region. The region is empty if the code has none.
+func splitLocaleCode(code string) (string, string) {
+	parts := strings.FieldsFunc(code, isLocaleCodeSeparator)
+	if len(parts) == 0 {
+		return "", ""
+	}
+	if last := parts[len(parts)-1]; len(parts) > 1 && localeRegionRegexp.MatchString(last) {
+		return parts[0], last
+	}
+	return parts[0], ""
+}
+
+func isLocaleCodeSeparator(r rune) bool {
+	return r == '-' || r == '_'
+}
+
+// Converts a locale code to an Android resource qualifier, e.g. `pt-BR` to
+// `pt-rBR` and `zh-Hant-TW` to `b+zh+Hant+TW`.
+func androidLocaleCode(code string) string {
+	parts := strings.FieldsFunc(code, isLocaleCodeSeparator)
+	switch {
+	case len(parts) < 2:
+		return code
+	case len(parts) == 2 && localeRegionRegexp.MatchString(parts[1]):
+		return parts[0] + "-r" + parts[1]
+	default:
+		return "b+" + strings.Join(parts, "+")
+	}
+}
+
+var androidRegionRegexp = regexp.MustCompile(`^([A-Za-z]{2,3})-r([A-Za-z]{2}|[0-9]{3})$`)
+
+func fromAndroidLocaleCode(qualifier string) string {
+	if strings.HasPrefix(qualifier, "b+") {
+		return strings.Join(strings.Split(qualifier[2:], "+"), "-")
+	}
+	if m := androidRegionRegexp.FindStringSubmatch(qualifier); m != nil {
+		return m[1] + "-" + m[2]
+	}
+	return qualifier
+}
+
+// Restores the conventional case of a lower case locale code: the language
+// in lower case, a script in title case and a region in upper case, e.g.
+// `zh-hant-tw` becomes `zh-Hant-TW`.
+func canonicalLocaleCode(code string) string {
+	parts := strings.FieldsFunc(code, isLocaleCodeSeparator)
+	if len(parts) < 2 {
+		return code
+	}
+
+	// keep the separator used in the code
+	sep := "-"
+	if strings.Contains(code, "_") {
+		sep = "_"
+	}
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		case localeRegionRegexp.MatchString(part):
+			parts[i] = strings.ToUpper(part)
+		}
+	}
+	return strings.Join(parts, sep)
+}
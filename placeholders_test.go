@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPlaceholderRoundTrip(t *testing.T) {
+	tt := []struct {
+		pattern string
+		code    string
+		path    string
+	}{
+		{"res/values-<locale_code|android>/strings.xml", "pt-BR", "res/values-pt-rBR/strings.xml"},
+		{"res/values-<locale_code|android>/strings.xml", "de", "res/values-de/strings.xml"},
+		{"res/values-<locale_code|android>/strings.xml", "zh-Hant-TW", "res/values-b+zh+Hant+TW/strings.xml"},
+		{"messages_<locale_code|underscore>.properties", "pt-BR", "messages_pt_BR.properties"},
+		{"<locale_code>.lproj/Localizable.strings", "pt-BR", "pt-BR.lproj/Localizable.strings"},
+		{"locales/<locale_code|lower>.json", "pt-BR", "locales/pt-br.json"},
+		{"locales/<locale_code|lower>.json", "zh-Hant-TW", "locales/zh-hant-tw.json"},
+		{"locales/<locale_code|underscore|lower>.json", "en-GB", "locales/en_gb.json"},
+		{"<language>/<region>.yml", "pt-BR", "pt/BR.yml"},
+		{"<language>/app.<language>_<region>.yml", "en-US", "en/app.en_US.yml"},
+	}
+
+	for _, tti := range tt {
+		path := replacePlaceholders(tti.pattern, &LocaleFile{Code: tti.code})
+		if path != tti.path {
+			t.Errorf("%s: expected path %q for %s, got %q", tti.pattern, tti.path, tti.code, path)
+			continue
+		}
+
+		g, err := compileGlob(tti.pattern)
+		if err != nil {
+			t.Errorf("%s: didn't expect an error, got: %s", tti.pattern, err)
+			continue
+		}
+		params, ok := g.match(path)
+		if !ok {
+			t.Errorf("%s: expected %q to match", tti.pattern, path)
+			continue
+		}
+
+		localeFile := new(LocaleFile)
+		setPlaceholderValues(localeFile, params)
+		if localeFile.Code != tti.code {
+			t.Errorf("%s: expected code %q to be extracted from %q, got %q", tti.pattern, tti.code, path, localeFile.Code)
+		}
+	}
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	if err := validatePlaceholders("values-<locale_code|android>/<tag|lower>.xml"); err != nil {
+		t.Errorf("didn't expect an error, got: %s", err)
+	}
+
+	err := validatePlaceholders("values-<locale_code|andriod>/strings.xml")
+	if err == nil || err.Error() != `unknown modifier "andriod" in placeholder <locale_code|andriod>` {
+		t.Errorf("expected an unknown modifier error, got: %v", err)
+	}
+}
+
+func TestLocaleFilesWithModifiers(t *testing.T) {
+	d := setupFiles(t,
+		"res/values/strings.xml",
+		"res/values-de/strings.xml",
+		"res/values-pt-rBR/strings.xml",
+	)
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	src := new(Source)
+	src.File = "./res/values-<locale_code|android>/strings.xml"
+
+	files, err := src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	codes := []string{}
+	for _, lf := range files {
+		codes = append(codes, lf.Code)
+	}
+	sort.Strings(codes)
+	if strings.Join(codes, " ") != "de pt-BR" {
+		t.Errorf("expected codes de and pt-BR, got %v", codes)
+	}
+}
+
+func TestTargetReplacePlaceholdersWithModifiers(t *testing.T) {
+	target := getBaseTarget()
+	target.File = "./res/values-<locale_code|android>/strings_<language>.xml"
+
+	path, err := target.ReplacePlaceholders(&LocaleFile{Code: "pt-BR"})
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if !strings.HasSuffix(path, filepath.FromSlash("/res/values-pt-rBR/strings_pt.xml")) {
+		t.Errorf("unexpected path %q", path)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// Postprocess canonicalizes pulled files before they are written, so that
+// changes of key order or formatting on the server don't show up as changes
+// of the local files. It is configured per target:
+//
+//	postprocess:
+//	  sort_keys: true          # sort keys alphabetically
+//	  indent: 4                # indentation of YAML, JSON and XML
+//	  trailing_newline: true   # end with exactly one newline (false: none)
+//	  line_endings: lf         # lf or crlf
+//	  bom: false               # add (true) or remove (false) a UTF-8 BOM
+//
+// Settings that aren't given keep the downloaded content as it is.
+type Postprocess struct {
+	SortKeys        bool
+	Indent          int
+	TrailingNewline *bool
+	LineEndings     string
+	BOM             *bool
+}
+
+func (pp *Postprocess) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var ignored []byte
+	err := phraseapp.ParseYAMLToMap(unmarshal, map[string]interface{}{
+		"sort_keys":        &pp.SortKeys,
+		"indent":           &pp.Indent,
+		"trailing_newline": &ignored,
+		"line_endings":     &pp.LineEndings,
+		"bom":              &ignored,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the optional settings are read separately, as the generic parsing
+	// can't tell a missing value from false
+	optional := struct {
+		TrailingNewline *bool `yaml:"trailing_newline"`
+		BOM             *bool `yaml:"bom"`
+	}{}
+	if err := unmarshal(&optional); err != nil {
+		return err
+	}
+	pp.TrailingNewline, pp.BOM = optional.TrailingNewline, optional.BOM
+
+	switch {
+	case pp.LineEndings != "" && pp.LineEndings != "lf" && pp.LineEndings != "crlf":
+		return fmt.Errorf("postprocess.line_endings must be lf or crlf, not %q", pp.LineEndings)
+	case pp.Indent < 0 || pp.Indent > 8:
+		return fmt.Errorf("postprocess.indent must be between 1 and 8, not %d", pp.Indent)
+	}
+	return nil
+}
+
+// formatter rewrites the content of a file format with the keys sorted if
+// sortKeys is set and the given indentation. An indent of 0 selects the
+// default of the format.
+type formatter func(content []byte, sortKeys bool, indent int) ([]byte, error)
+
+var formatters = map[string]formatter{
+	"yml":               formatYAML,
+	"yml_symfony":       formatYAML,
+	"yml_symfony2":      formatYAML,
+	"simple_json":       formatJSON,
+	"nested_json":       formatJSON,
+	"react_simple_json": formatJSON,
+	"react_nested_json": formatJSON,
+	"i18next":           formatJSON,
+	"go_i18n":           formatJSON,
+	"angular_translate": formatJSON,
+	"properties":        formatProperties,
+	"strings":           formatStrings,
+	"xml":               formatAndroidXML,
+}
+
+func (pp *Postprocess) reformats() bool {
+	return pp != nil && (pp.SortKeys || pp.Indent > 0)
+}
+
+// Checks that the file format can be reformatted if needed.
+func (pp *Postprocess) check(format string) error {
+	if _, found := formatters[format]; pp.reformats() && !found {
+		return fmt.Errorf("postprocess sort_keys and indent are not supported for the file format %q", format)
+	}
+	return nil
+}
+
+// Applies the post processing to the content of a file of the format.
+func (pp *Postprocess) apply(format string, content []byte) ([]byte, error) {
+	if pp == nil {
+		return content, nil
+	}
+
+	hasBOM := bytes.HasPrefix(content, utf8BOM)
+	content = bytes.TrimPrefix(content, utf8BOM)
+
+	crlf := bytes.Contains(content, []byte("\r\n"))
+	content = bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+
+	if pp.reformats() && len(bytes.TrimSpace(content)) > 0 {
+		var err error
+		if content, err = formatters[format](content, pp.SortKeys, pp.Indent); err != nil {
+			return nil, fmt.Errorf("could not post process %s content: %s", format, err)
+		}
+	}
+
+	if pp.TrailingNewline != nil {
+		content = bytes.TrimRight(content, "\n")
+		if *pp.TrailingNewline && len(content) > 0 {
+			content = append(content, '\n')
+		}
+	}
+
+	switch pp.LineEndings {
+	case "crlf":
+		crlf = true
+	case "lf":
+		crlf = false
+	}
+	if crlf {
+		content = bytes.Replace(content, []byte("\n"), []byte("\r\n"), -1)
+	}
+
+	if pp.BOM != nil {
+		hasBOM = *pp.BOM
+	}
+	if hasBOM {
+		content = append(append([]byte{}, utf8BOM...), content...)
+	}
+	return content, nil
+}
+
+// sortableEntry is an entry of a line based file format, including the
+// comments and blank lines preceding it.
+type sortableEntry struct {
+	key  string
+	text string
+}
+
+// Joins the entries sorted by key. A comment block at the beginning of the
+// file that is separated from the first entry by a blank line stays in front.
+func joinSorted(entries []sortableEntry, trailer string) []byte {
+	buf := new(bytes.Buffer)
+	if len(entries) > 0 {
+		if i := bytes.LastIndex([]byte(entries[0].text), []byte("\n\n")); i >= 0 {
+			buf.WriteString(entries[0].text[:i+2])
+			entries[0].text = entries[0].text[i+2:]
+		}
+	}
+
+	sort.Stable(byKey(entries))
+	for _, entry := range entries {
+		buf.WriteString(entry.text)
+	}
+	buf.WriteString(trailer)
+	return buf.Bytes()
+}
+
+type byKey []sortableEntry
+
+func (s byKey) Len() int           { return len(s) }
+func (s byKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byKey) Less(i, j int) bool { return s[i].key < s[j].key }
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// YAML files are reformatted line by line, as decoding and encoding them
+// would change how scalars are written, e.g. `y` would become `true`. Each
+// mapping key starts a node that ends before the next line indented the same
+// or less. Lines of block scalars, wrapped strings and sequence items belong
+// to the node they continue and are only shifted.
+func formatYAML(content []byte, sortKeys bool, indent int) ([]byte, error) {
+	root, pending := parseYAML(content)
+	buf := new(bytes.Buffer)
+	root.write(buf, sortKeys, indent, -indent)
+	for _, line := range pending {
+		buf.WriteString(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// Parses the content into a tree of nodes. Comments and blank lines at the
+// end of the file are returned separately.
+func parseYAML(content []byte) (*yamlNode, []string) {
+	root := &yamlNode{indent: -1, isBlock: true, sortable: true}
+	stack := []*yamlNode{root}
+	pending := []string{}
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+		text := strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimLeft(text, " ")
+		lead := len(text) - len(trimmed)
+
+		for {
+			top := stack[len(stack)-1]
+			switch {
+			case !top.isBlock && (trimmed == "" || lead > top.indent):
+				// continues the value of the node
+				top.lines = append(top.lines, line)
+			case trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---":
+				pending = append(pending, line)
+			case lead > top.indent || (lead == top.indent && isYAMLSequenceItem(trimmed) && len(top.children) == 0 && top != root):
+				node := newYAMLNode(trimmed, lead)
+				node.lines = append(pending, line)
+				pending = []string{}
+				if node.key == "" {
+					top.sortable = false
+				}
+				top.children = append(top.children, node)
+				stack = append(stack, node)
+			default:
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			break
+		}
+	}
+	return root, pending
+}
+
+type yamlNode struct {
+	key    string
+	indent int
+	lines  []string
+
+	// isBlock is set for keys whose value is a block mapping or sequence on
+	// the following lines.
+	isBlock  bool
+	children []*yamlNode
+
+	// sortable is cleared if any child isn't a mapping key.
+	sortable bool
+}
+
+var yamlKeyRegexp = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s"'#?{\[\]}&*!|>%@,` + "`" + `-][^#]*?|-[^\s#][^#]*?):(?:\s+(.*))?$`)
+
+func isYAMLSequenceItem(trimmed string) bool {
+	return trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+}
+
+func newYAMLNode(trimmed string, lead int) *yamlNode {
+	node := &yamlNode{indent: lead, sortable: true}
+	m := yamlKeyRegexp.FindStringSubmatch(trimmed)
+	if m == nil || isYAMLSequenceItem(trimmed) {
+		return node
+	}
+
+	node.key = strings.Trim(m[1], `"'`)
+	value := m[2]
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	// anchors and tags may precede the block value
+	node.isBlock = true
+	for _, field := range strings.Fields(value) {
+		if field[0] != '&' && field[0] != '!' {
+			node.isBlock = false
+		}
+	}
+	return node
+}
+
+// Writes the node and its children. The node is moved to column if indent is
+// set, all its lines are shifted by the same amount.
+func (node *yamlNode) write(buf *bytes.Buffer, sortKeys bool, indent, column int) {
+	if indent == 0 {
+		column = node.indent
+	}
+	for _, line := range node.lines {
+		buf.WriteString(shiftLine(line, column-node.indent))
+	}
+
+	if sortKeys && node.sortable {
+		sort.Stable(yamlNodesByKey(node.children))
+	}
+	for _, child := range node.children {
+		childColumn := column + indent
+		if child.indent == node.indent {
+			// sequences may have the indentation of their key
+			childColumn = column
+		}
+		child.write(buf, sortKeys, indent, childColumn)
+	}
+}
+
+type yamlNodesByKey []*yamlNode
+
+func (s yamlNodesByKey) Len() int           { return len(s) }
+func (s yamlNodesByKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s yamlNodesByKey) Less(i, j int) bool { return s[i].key < s[j].key }
+
+// jsonObject is a JSON object with the order of its keys preserved.
+type jsonObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (obj *jsonObject) Len() int { return len(obj.keys) }
+func (obj *jsonObject) Swap(i, j int) {
+	obj.keys[i], obj.keys[j] = obj.keys[j], obj.keys[i]
+	obj.values[i], obj.values[j] = obj.values[j], obj.values[i]
+}
+func (obj *jsonObject) Less(i, j int) bool { return obj.keys[i] < obj.keys[j] }
+
+func formatJSON(content []byte, sortKeys bool, indent int) ([]byte, error) {
+	v, err := parseJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if indent == 0 {
+		indent = 2
+	}
+	buf := new(bytes.Buffer)
+	writeJSON(buf, v, sortKeys, strings.Repeat(" ", indent), "")
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// Parses the content keeping the order of object keys and numbers as they
+// are written.
+func parseJSON(content []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected content after the JSON value")
+	}
+	return v, nil
+}
+
+func decodeJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := new(jsonObject)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values = append(obj.values, value)
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}, sortKeys bool, indent, prefix string) {
+	switch v := v.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		if sortKeys {
+			sort.Stable(v)
+		}
+		buf.WriteString("{\n")
+		for i, key := range v.keys {
+			buf.WriteString(prefix + indent)
+			writeJSONString(buf, key)
+			buf.WriteString(": ")
+			writeJSON(buf, v.values[i], sortKeys, indent, prefix+indent)
+			if i < len(v.keys)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(prefix + "}")
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, value := range v {
+			buf.WriteString(prefix + indent)
+			writeJSON(buf, value, sortKeys, indent, prefix+indent)
+			if i < len(v)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(prefix + "]")
+	case string:
+		writeJSONString(buf, v)
+	case json.Number:
+		buf.WriteString(v.String())
+	case bool:
+		fmt.Fprint(buf, v)
+	case nil:
+		buf.WriteString("null")
+	}
+}
+
+// Writes the string quoted, escaping only what JSON requires, so that
+// characters like < or non-ASCII letters stay readable.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < 0x20 || r == utf8.RuneError:
+			fmt.Fprintf(buf, `\u%04x`, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// Sorts the entries of a .properties file. Comments and blank lines belong
+// to the entry following them, lines ending with a backslash continue on the
+// next line. Indentation doesn't apply to the format.
+func formatProperties(content []byte, sortKeys bool, indent int) ([]byte, error) {
+	if !sortKeys {
+		return content, nil
+	}
+	entries, trailer := propertiesEntries(content)
+	return joinSorted(entries, trailer), nil
+}
+
+// Splits a .properties file into its entries and the comments and blank
+// lines following the last entry.
+func propertiesEntries(content []byte) ([]sortableEntry, string) {
+	entries := []sortableEntry{}
+	pending := ""
+	continued := false
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		text := strings.TrimRight(line, "\n")
+		trimmed := strings.TrimLeft(text, " \t\f")
+
+		switch {
+		case continued:
+			entries[len(entries)-1].text += line
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!':
+			pending += line
+			continue
+		default:
+			entries = append(entries, sortableEntry{key: propertiesKey(trimmed), text: pending + line})
+			pending = ""
+		}
+		continued = strings.HasSuffix(text, "\\") && (len(text)-len(strings.TrimRight(text, "\\")))%2 == 1
+	}
+
+	if len(entries) > 0 && !strings.HasSuffix(entries[len(entries)-1].text, "\n") {
+		entries[len(entries)-1].text += "\n"
+	}
+	return entries, pending
+}
+
+func propertiesKey(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// Sorts the entries of an Apple .strings file. An entry ends with the
+// semicolon after its value and includes the comments preceding it.
+// Indentation doesn't apply to the format.
+func formatStrings(content []byte, sortKeys bool, indent int) ([]byte, error) {
+	if !sortKeys {
+		return content, nil
+	}
+	entries, trailer, err := stringsEntries(content)
+	if err != nil {
+		return nil, err
+	}
+	return joinSorted(entries, trailer), nil
+}
+
+// Splits a .strings file into its entries and the content following the
+// last entry.
+func stringsEntries(content []byte) ([]sortableEntry, string, error) {
+	s := string(content)
+	entries := []sortableEntry{}
+	start, keyStart, keyEnd := 0, -1, -1
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated comment")
+			}
+			i += end + 3
+		case strings.HasPrefix(s[i:], "//"):
+			if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(s)
+			}
+		case s[i] == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, "", fmt.Errorf("unterminated string")
+			}
+			if keyStart < 0 {
+				keyStart, keyEnd = i+1, j
+			}
+			i = j
+		case s[i] == ';':
+			// the rest of the line belongs to the entry if it is a comment
+			end := i + 1
+			nl := strings.IndexByte(s[end:], '\n')
+			if nl < 0 {
+				nl = len(s) - end
+			}
+			if rest := strings.TrimSpace(s[end : end+nl]); rest == "" || strings.HasPrefix(rest, "//") ||
+				(strings.HasPrefix(rest, "/*") && strings.HasSuffix(rest, "*/")) {
+				end += nl
+				if end < len(s) {
+					end++
+				}
+			}
+			text := s[start:end]
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			key := ""
+			if keyStart >= 0 {
+				key = s[keyStart:keyEnd]
+			}
+			entries = append(entries, sortableEntry{key: key, text: text})
+			start, keyStart, i = end, -1, end-1
+		}
+	}
+	return entries, s[start:], nil
+}
+
+// Sorts the resources of an Android XML file by name and indents them. The
+// elements themselves are kept as they are, only their lines are shifted
+// to the new indentation.
+func formatAndroidXML(content []byte, sortKeys bool, indent int) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	var bodyStart, bodyEnd int64 = -1, -1
+	resources := []xmlResource{}
+	depth := 0
+	var current xmlResource
+	pendingStart := int64(-1)
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 1:
+				if tok.Name.Local != "resources" {
+					return nil, fmt.Errorf("expected a resources element, found %s", tok.Name.Local)
+				}
+				bodyStart = dec.InputOffset()
+			case 2:
+				current = xmlResource{start: offset}
+				if pendingStart >= 0 {
+					current.start = pendingStart
+					pendingStart = -1
+				}
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "name" {
+						current.name = attr.Value
+					}
+				}
+			}
+		case xml.EndElement:
+			switch depth {
+			case 1:
+				bodyEnd = offset
+			case 2:
+				current.end = dec.InputOffset()
+				resources = append(resources, current)
+			}
+			depth--
+		case xml.Comment, xml.ProcInst, xml.Directive:
+			// comments in the resources belong to the following element
+			if depth == 1 && pendingStart < 0 {
+				pendingStart = offset
+			}
+		}
+	}
+	if bodyStart < 0 || bodyEnd < 0 {
+		return nil, fmt.Errorf("no resources element found")
+	}
+	var trailer *xmlResource
+	if pendingStart >= 0 {
+		trailer = &xmlResource{start: pendingStart, end: bodyEnd}
+	}
+
+	if indent == 0 {
+		indent = 4
+		if len(resources) > 0 {
+			indent = xmlColumn(content, resources[0].start)
+		}
+	}
+	if sortKeys {
+		sort.Stable(xmlResourcesByName(resources))
+	}
+	if trailer != nil {
+		resources = append(resources, *trailer)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(content[:bodyStart])
+	buf.WriteString("\n")
+	for _, r := range resources {
+		shift := indent - xmlColumn(content, r.start)
+		for i, line := range strings.SplitAfter(strings.TrimSpace(string(content[r.start:r.end])), "\n") {
+			if i == 0 {
+				line = strings.Repeat(" ", indent) + line
+			} else {
+				line = shiftLine(line, shift)
+			}
+			buf.WriteString(line)
+		}
+		buf.WriteString("\n")
+	}
+	buf.Write(content[bodyEnd:])
+	return buf.Bytes(), nil
+}
+
+// xmlResource is the byte range of an element in the resources of an
+// Android XML file, including the comments preceding it.
+type xmlResource struct {
+	name       string
+	start, end int64
+}
+
+type xmlResourcesByName []xmlResource
+
+func (s xmlResourcesByName) Len() int           { return len(s) }
+func (s xmlResourcesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s xmlResourcesByName) Less(i, j int) bool { return s[i].name < s[j].name }
+
+// Returns the column of the offset if only spaces precede it on its line.
+func xmlColumn(content []byte, offset int64) int {
+	lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+	prefix := content[lineStart:offset]
+	if len(bytes.TrimLeft(prefix, " ")) > 0 {
+		return 0
+	}
+	return len(prefix)
+}
+
+func shiftLine(line string, shift int) string {
+	if shift == 0 || strings.TrimSpace(line) == "" {
+		return line
+	}
+	if shift > 0 {
+		return strings.Repeat(" ", shift) + line
+	}
+	lead := len(line) - len(strings.TrimLeft(line, " "))
+	if lead > -shift {
+		lead = -shift
+	}
+	return line[lead:]
+}
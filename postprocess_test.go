@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+)
+
+func TestPostprocessFormats(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		indent   int
+		content  string
+		expected string
+	}{
+		{
+			format:   "yml",
+			content:  "en:\n  b: x\n  # about a\n  a:\n    d: y\n    c: 'z' # c\n",
+			expected: "en:\n  # about a\n  a:\n    c: 'z' # c\n    d: y\n  b: x\n",
+		},
+		{
+			format:   "yml",
+			indent:   4,
+			content:  "en:\n  b:\n  - x\n  - c: 1\n    a: 2\n  a: |-\n    line\n      indented\n",
+			expected: "en:\n    a: |-\n      line\n        indented\n    b:\n    - x\n    - c: 1\n      a: 2\n",
+		},
+		{
+			format:   "nested_json",
+			indent:   4,
+			content:  `{"b": {"y": 1, "x": [true, null]}, "a": "<\u00e9>", "c": {}}`,
+			expected: "{\n    \"a\": \"<é>\",\n    \"b\": {\n        \"x\": [\n            true,\n            null\n        ],\n        \"y\": 1\n    },\n    \"c\": {}\n}\n",
+		},
+		{
+			format:   "properties",
+			content:  "# header\n\n# about b\nb=2\na = 1 \\\n  continued\n! trailing\n",
+			expected: "# header\n\na = 1 \\\n  continued\n# about b\nb=2\n! trailing\n",
+		},
+		{
+			format:   "strings",
+			content:  "/* b */\n\"b\" = \"x;y\";\n\"a\" = \"z\"; // a\n",
+			expected: "\"a\" = \"z\"; // a\n/* b */\n\"b\" = \"x;y\";\n",
+		},
+		{
+			format: "xml",
+			indent: 2,
+			content: "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<resources>\n    <!-- b -->\n    <string name=\"b\">B</string>\n" +
+				"    <plurals name=\"a\">\n        <item quantity=\"one\">A</item>\n    </plurals>\n</resources>\n",
+			expected: "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<resources>\n  <plurals name=\"a\">\n      <item quantity=\"one\">A</item>\n  </plurals>\n" +
+				"  <!-- b -->\n  <string name=\"b\">B</string>\n</resources>\n",
+		},
+	} {
+		pp := &Postprocess{SortKeys: true, Indent: tc.indent}
+		out, err := pp.apply(tc.format, []byte(tc.content))
+		if err != nil {
+			t.Errorf("%s: didn't expect an error, got: %s", tc.format, err)
+			continue
+		}
+		if string(out) != tc.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tc.format, tc.expected, out)
+		}
+	}
+}
+
+func TestPostprocessLineEndings(t *testing.T) {
+	yes, no := true, false
+
+	pp := &Postprocess{TrailingNewline: &yes, LineEndings: "crlf", BOM: &yes}
+	out, err := pp.apply("gettext", []byte("a\nb\n\n\n"))
+	if err != nil || string(out) != "\xef\xbb\xbfa\r\nb\r\n" {
+		t.Errorf("expected CRLF line endings with BOM, got %q (%v)", out, err)
+	}
+
+	pp = &Postprocess{TrailingNewline: &no, LineEndings: "lf", BOM: &no}
+	out, err = pp.apply("gettext", []byte("\xef\xbb\xbfa\r\nb\r\n"))
+	if err != nil || string(out) != "a\nb" {
+		t.Errorf("expected LF line endings without BOM, got %q (%v)", out, err)
+	}
+
+	// unset settings keep the content
+	out, err = new(Postprocess).apply("gettext", []byte("\xef\xbb\xbfa\r\n"))
+	if err != nil || string(out) != "\xef\xbb\xbfa\r\n" {
+		t.Errorf("expected the content to be kept, got %q (%v)", out, err)
+	}
+}
+
+func TestPostprocessConfig(t *testing.T) {
+	var target Target
+	err := yaml.Unmarshal([]byte("file: ./<locale_code>.yml\npostprocess:\n  sort_keys: true\n  trailing_newline: false\n"), &target)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if pp := target.Postprocess; pp == nil || !pp.SortKeys || pp.TrailingNewline == nil || *pp.TrailingNewline || pp.BOM != nil {
+		t.Errorf("expected the postprocess settings to be parsed, got %+v", pp)
+	}
+
+	err = yaml.Unmarshal([]byte("postprocess:\n  line_endings: cr\n"), &target)
+	if err == nil || !strings.Contains(err.Error(), "line_endings") {
+		t.Errorf("expected an error for invalid line endings, got: %v", err)
+	}
+
+	if err := (&Postprocess{SortKeys: true}).check("gettext"); err == nil {
+		t.Errorf("expected an error for a format without formatter")
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync/atomic"
+	"time"
 
 	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
 
@@ -15,6 +18,27 @@ import (
 
 type PullCommand struct {
 	*phraseapp.Config
+
+	Only     []string `cli:"opt --only desc='Pull only the targets with these names (comma separated)'"`
+	Except   []string `cli:"opt --except desc='Skip the targets with these names (comma separated)'"`
+	Locales  []string `cli:"opt --locale desc='Pull only these locales by code, name or ID, overriding locales and exclude_locales of the targets (comma separated)'"`
+	Branch   string   `cli:"opt --branch desc='Pull only keys tagged with this git branch (see branch_tag)'"`
+	Parallel int      `cli:"opt --parallel desc='Number of concurrent downloads (default: pull.concurrency or 1)'"`
+	Check    bool     `cli:"opt --check desc='Only check whether the local files are up to date, without writing them'"`
+	Diff     bool     `cli:"opt --diff desc='Show a diff of the files that are out of date (with --check)'"`
+	NoCache  bool     `cli:"opt --no-cache desc='Download all files, even if they did not change since the last pull'"`
+	Archive  string   `cli:"opt --archive desc='Write all pulled files into this .zip or .tar.gz archive instead'"`
+
+	PreferLocal bool `cli:"opt --prefer-local desc='Keep the local values of keys in both files when merging (see merge)'"`
+
+	Restore       bool   `cli:"opt --restore desc='Restore the files changed by the last pull, or by the given snapshot'"`
+	ListSnapshots bool   `cli:"opt --list-snapshots desc='List the snapshots of files changed by pulls'"`
+	Snapshot      string `cli:"arg"`
+
+	clientConfig clientConfig
+
+	// number of snapshots kept, set from the pull config
+	snapshots int
 }
 
 func (cmd *PullCommand) Run() error {
@@ -23,6 +47,44 @@ func (cmd *PullCommand) Run() error {
 		cmd.Debug = false
 		Debug = true
 	}
+
+	switch {
+	case cmd.Restore && cmd.ListSnapshots:
+		return fmt.Errorf("--restore can't be combined with --list-snapshots")
+	case cmd.Snapshot != "" && !cmd.Restore:
+		return fmt.Errorf("a snapshot can only be given with --restore")
+	case cmd.Restore:
+		return restoreSnapshot(snapshotsDir, cmd.Snapshot)
+	case cmd.ListSnapshots:
+		return printSnapshots(snapshotsDir)
+	}
+
+	hooks, err := HooksFromConfig(&cmd.clientConfig)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Diff && !cmd.Check {
+		return fmt.Errorf("--diff requires --check")
+	}
+	if cmd.Archive != "" {
+		if cmd.Check {
+			return fmt.Errorf("--archive can't be combined with --check")
+		}
+		if err := checkArchivePath(cmd.Archive); err != nil {
+			return err
+		}
+	}
+
+	// check mode doesn't change any files, so no hooks are run
+	if cmd.Check {
+		hooks = new(Hooks)
+	}
+
+	if err := hooks.BeforePull.run("before_pull", nil, nil); err != nil {
+		return err
+	}
+
 	client, err := newClient(cmd.Config.Credentials)
 	if err != nil {
 		return err
@@ -33,25 +95,124 @@ func (cmd *PullCommand) Run() error {
 		return err
 	}
 
+	downloads := []*download{}
 	for _, target := range targets {
-		err := target.Pull(client)
+		if cmd.Check && target.Archive != "" {
+			// there are no local files to compare with
+			continue
+		}
+		d, err := target.downloads(client)
 		if err != nil {
 			return err
 		}
+		downloads = append(downloads, d...)
+	}
+	for _, d := range downloads {
+		d.archive = d.target.Archive
+		if cmd.Archive != "" {
+			d.archive = cmd.Archive
+		}
+	}
+
+	p := &puller{client: client, hooks: hooks, check: cmd.Check, diff: cmd.Diff}
+	if !cmd.Check && !cmd.NoCache {
+		if p.cache, err = loadDownloadCache(downloadCachePath); err != nil {
+			return err
+		}
+	}
+	if !cmd.Check && cmd.snapshots > 0 {
+		p.snapshot = newSnapshot(snapshotsDir, time.Now())
+	}
+
+	// files downloaded successfully are recorded even if others failed
+	err = p.pull(downloads, cmd.Parallel)
+	if p.cache != nil {
+		if saveErr := p.cache.save(); err == nil {
+			err = saveErr
+		}
+	}
+	if p.snapshot != nil {
+		if saveErr := p.snapshot.save(cmd.snapshots); err == nil {
+			err = saveErr
+		}
+		if p.snapshot.name != "" {
+			fmt.Printf("Saved the previous files as snapshot %s (undo with: phraseapp pull --restore %s)\n", p.snapshot.name, p.snapshot.name)
+		}
+	}
+	if err != nil {
+		return err
 	}
 
+	if err := writeArchives(downloads); err != nil {
+		return err
+	}
+
+	return hooks.AfterPull.run("after_pull", nil, nil)
+}
+
+// Writes the downloads of archive targets to their archives.
+func writeArchives(downloads []*download) error {
+	paths := []string{}
+	archives := map[string][]*download{}
+	for _, d := range downloads {
+		if d.archive == "" {
+			continue
+		}
+		if archives[d.archive] == nil {
+			paths = append(paths, d.archive)
+		}
+		archives[d.archive] = append(archives[d.archive], d)
+	}
+
+	createdAt := time.Now()
+	for _, path := range paths {
+		if err := writeArchive(path, archives[path], createdAt); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d files to %s\n", len(archives[path]), path)
+	}
 	return nil
 }
 
 type Targets []*Target
 
 type Target struct {
+	Name          string
 	File          string
 	ProjectID     string
 	AccessToken   string
 	FileFormat    string
 	Params        *PullParams
 	RemoteLocales []*phraseapp.Locale
+	LocaleMapping LocaleMapping
+
+	// Locales limits the target to these locales, ExcludeLocales skips
+	// locales. Both match the code, name or ID of a locale.
+	Locales        []string
+	ExcludeLocales []string
+
+	// Archive is the .zip or .tar.gz file the locale files are written to
+	// instead of the file system if set.
+	Archive string
+
+	// Tags and TagPattern select the tags a target with a <tag>
+	// placeholder is pulled for (see expandsTags).
+	Tags       []string
+	TagPattern string
+
+	// FileMode and DirMode are the permissions of created files and
+	// directories. Existing files keep their mode.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+
+	// Postprocess is applied to downloaded files before they are written.
+	Postprocess *Postprocess
+
+	// Merge deep-merges downloaded files into the local files, keeping keys
+	// that only exist locally. Local values of keys in both files are kept
+	// if PreferLocal is set.
+	Merge       bool
+	PreferLocal bool
 }
 
 type PullParams struct {
@@ -61,17 +222,56 @@ type PullParams struct {
 
 func (tgt *Target) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	m := map[string]interface{}{}
+	var ignored, postprocess, locales, excludeLocales, tags []byte
 	err := phraseapp.ParseYAMLToMap(unmarshal, map[string]interface{}{
-		"file":         &tgt.File,
-		"project_id":   &tgt.ProjectID,
-		"access_token": &tgt.AccessToken,
-		"file_format":  &tgt.FileFormat,
-		"params":       &m,
+		"name":            &tgt.Name,
+		"file":            &tgt.File,
+		"project_id":      &tgt.ProjectID,
+		"access_token":    &tgt.AccessToken,
+		"file_format":     &tgt.FileFormat,
+		"file_mode":       &ignored,
+		"dir_mode":        &ignored,
+		"postprocess":     &postprocess,
+		"locales":         &locales,
+		"exclude_locales": &excludeLocales,
+		"tags":            &tags,
+		"tag_pattern":     &tgt.TagPattern,
+		"archive":         &tgt.Archive,
+		"merge":           &tgt.Merge,
+		"params":          &m,
 	})
 	if err != nil {
 		return err
 	}
 
+	if tgt.Locales, err = parseStringList("locales", locales); err != nil {
+		return err
+	}
+	if tgt.ExcludeLocales, err = parseStringList("exclude_locales", excludeLocales); err != nil {
+		return err
+	}
+	if tgt.Tags, err = parseStringList("tags", tags); err != nil {
+		return err
+	}
+
+	if postprocess != nil {
+		tgt.Postprocess = new(Postprocess)
+		if err := yaml.Unmarshal(postprocess, tgt.Postprocess); err != nil {
+			return err
+		}
+	}
+
+	// modes are read separately, as the generic parsing would lose the
+	// octal notation of the values
+	modes := struct {
+		FileMode fileMode `yaml:"file_mode"`
+		DirMode  fileMode `yaml:"dir_mode"`
+	}{}
+	if err := unmarshal(&modes); err != nil {
+		return err
+	}
+	tgt.FileMode, tgt.DirMode = os.FileMode(modes.FileMode), os.FileMode(modes.DirMode)
+
 	tgt.Params = new(PullParams)
 	if v, found := m["locale_id"]; found {
 		if tgt.Params.LocaleID, err = phraseapp.ValidateIsString("params.locale_id", v); err != nil {
@@ -109,55 +309,324 @@ func (target *Target) CheckPreconditions() error {
 		return fmt.Errorf(fmt.Sprintf("%s can only occur once in a file pattern!", dups))
 	}
 
-	return nil
-}
+	if err := target.Postprocess.check(target.GetFormat()); err != nil {
+		return err
+	}
 
-func (target *Target) Pull(client *phraseapp.Client) error {
+	if err := target.checkTags(); err != nil {
+		return err
+	}
 
+	if target.Merge {
+		if err := checkMerge(target.GetFormat()); err != nil {
+			return err
+		}
+	}
+
+	return validatePlaceholders(target.File)
+}
+
+// Returns the downloads of the target, one per locale file.
+func (target *Target) downloads(client *phraseapp.Client) ([]*download, error) {
 	if err := target.CheckPreconditions(); err != nil {
-		return err
+		return nil, err
 	}
 
-	remoteLocales, err := RemoteLocales(client, target.ProjectID)
-	if err != nil {
+	var remoteLocales []*phraseapp.Locale
+	err := rateLimit.do(func() (err error) {
+		remoteLocales, err = RemoteLocales(client, target.ProjectID)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 	target.RemoteLocales = remoteLocales
 
-	localeFiles, err := target.LocaleFiles()
-	if err != nil {
+	targets := []*Target{target}
+	if target.expandsTags() {
+		if targets, err = target.perTag(client); err != nil {
+			return nil, err
+		}
+	}
+
+	downloads := []*download{}
+	for _, target := range targets {
+		localeFiles, err := target.LocaleFiles()
+		if err != nil {
+			return nil, err
+		}
+
+		localeIdToFileIsDistinct := (target.GetLocaleID() != "" && len(localeFiles) == 1)
+
+		for _, localeFile := range localeFiles {
+			if localeIdToFileIsDistinct {
+				if target.GetLocaleID() != "" {
+					localeFile.ID = target.GetLocaleID()
+				}
+			}
+			downloads = append(downloads, &download{target: target, localeFile: localeFile})
+		}
+	}
+
+	// placeholders like <language> can map several locales to the same file
+	if err := checkDistinctPaths(downloads); err != nil {
+		return nil, err
+	}
+	return downloads, nil
+}
+
+// Returns an error listing the locales of every file more than one of the
+// downloads would be written to.
+func checkDistinctPaths(downloads []*download) error {
+	paths, locales := []string{}, map[string][]string{}
+	for _, d := range downloads {
+		lf := d.localeFile
+		if _, found := locales[lf.Path]; !found {
+			paths = append(paths, lf.Path)
+		}
+		description := fmt.Sprintf("%s (id: %s, code: %s)", lf.Name, lf.ID, lf.Code)
+		if lf.Tag != "" {
+			description += " tagged " + lf.Tag
+		}
+		locales[lf.Path] = append(locales[lf.Path], description)
+	}
+
+	conflicts := []string{}
+	for _, path := range paths {
+		if len(locales[path]) > 1 {
+			rel := (&LocaleFile{Path: path}).RelPath()
+			conflicts = append(conflicts, fmt.Sprintf("%s would be written by several locales:\n\t%s", rel, strings.Join(locales[path], "\n\t")))
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%s\nUse placeholders that tell these locales apart, or limit the target with locales or exclude_locales.", strings.Join(conflicts, "\n"))
+	}
+	return nil
+}
+
+// download is a locale file of a target to be pulled.
+type download struct {
+	target     *Target
+	localeFile *LocaleFile
+
+	// the downloaded and the local content in check mode
+	remote, local []byte
+	missing       bool
+
+	// set if the locale didn't change since the last pull
+	notModified bool
+
+	// the local-only keys kept when merging
+	kept []string
+
+	// the output of the per-file hooks, printed with the report of the file
+	hookOutput bytes.Buffer
+
+	// the file is added to this archive instead of being written if set
+	archive string
+}
+
+func (d *download) upToDate() bool {
+	return !d.missing && bytes.Equal(d.remote, d.local)
+}
+
+// puller downloads the locale files of the targets.
+type puller struct {
+	client *phraseapp.Client
+
+	// hooks are run before and after each download if set.
+	hooks *Hooks
+
+	// cache makes downloads conditional on changes since the last pull if
+	// set.
+	cache *downloadCache
+
+	// snapshot records the previous contents of changed files if set.
+	snapshot *snapshot
+
+	// check compares the downloaded content with the local files instead of
+	// writing it, diff adds a unified diff of the differing files.
+	check, diff bool
+}
+
+// Downloads the files using at most workers concurrent downloads. A failed
+// download doesn't stop the others, all failures are reported at the end. A
+// failing hook aborts the pull though, and its error is returned ahead of
+// the failed downloads.
+func (p *puller) pull(downloads []*download, workers int) error {
+	var hookFailed int32
+	work := func(i int) error {
+		if atomic.LoadInt32(&hookFailed) == 1 {
+			return errSkipped
+		}
+		err := p.download(downloads[i])
+		if _, ok := err.(*hookError); ok {
+			atomic.StoreInt32(&hookFailed, 1)
+		}
 		return err
 	}
 
-	localeIdToFileIsDistinct := (target.GetLocaleID() != "" && len(localeFiles) == 1)
+	var hookErr *hookError
+	failures := []error{}
+	outdated := 0
+	report := func(i int, err error) {
+		d := downloads[i]
+		os.Stdout.Write(d.hookOutput.Bytes())
+		switch herr, isHookErr := err.(*hookError); {
+		case isHookErr && hookErr == nil:
+			hookErr = herr
+			return
+		case err != nil:
+			failures = append(failures, err)
+			return
+		case d.notModified:
+			fmt.Println("Skipping", d.localeFile.RelPath(), "(unchanged since last pull)")
+		case d.archive != "":
+			fmt.Println("Downloaded", d.localeFile.Message(), "for", d.archive)
+		case !p.check:
+			sharedMessage("pull", d.localeFile)
+			if len(d.kept) > 0 {
+				fmt.Printf("Kept %d local-only keys in %s: %s\n", len(d.kept), d.localeFile.RelPath(), strings.Join(d.kept, ", "))
+			}
+		case d.missing:
+			outdated++
+			fmt.Println("Missing", d.localeFile.RelPath())
+		case !d.upToDate():
+			outdated++
+			fmt.Println("Out of date", d.localeFile.RelPath())
+			if p.diff {
+				fmt.Print(unifiedDiff(d.localeFile.RelPath(), d.localeFile.RelPath()+" (PhraseApp)", d.local, d.remote))
+			}
+		case Debug:
+			fmt.Fprintln(os.Stderr, "Up to date", d.localeFile.RelPath())
+		}
 
-	for _, localeFile := range localeFiles {
-		err := createFile(localeFile.Path)
-		if err != nil {
+		if Debug {
+			fmt.Fprintln(os.Stderr, strings.Repeat("-", 10))
+		}
+	}
+
+	forEachParallel(len(downloads), workers, false, work, report)
+
+	if hookErr != nil {
+		for _, err := range failures {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return hookErr
+	}
+
+	switch len(failures) {
+	case 0:
+	case 1:
+		return failures[0]
+	default:
+		fmt.Fprintf(os.Stderr, "%d of %d downloads failed:\n", len(failures), len(downloads))
+		for _, err := range failures {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+		return fmt.Errorf("%d of %d downloads failed", len(failures), len(downloads))
+	}
+
+	if outdated > 0 {
+		return fmt.Errorf("%d of %d files are out of date with PhraseApp", outdated, len(downloads))
+	}
+	return nil
+}
+
+func (p *puller) download(d *download) error {
+	target, localeFile := d.target, d.localeFile
+
+	if p.check || d.archive != "" {
+		var res *phraseapp.LocaleDownloadResponse
+		err := rateLimit.do(func() (err error) {
+			res, err = target.Download(p.client, localeFile, nil)
 			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s for %s", err, localeFile.Path)
 		}
 
-		if localeIdToFileIsDistinct {
-			if target.GetLocaleID() != "" {
-				localeFile.ID = target.GetLocaleID()
+		// archived files aren't merged, as they don't replace the local ones
+		if p.check {
+			d.local, err = ioutil.ReadFile(localeFile.Path)
+			if os.IsNotExist(err) {
+				d.missing = true
+			} else if err != nil {
+				return err
 			}
 		}
-
-		err = target.DownloadAndWriteToFile(client, localeFile)
+		d.remote, _, err = target.process(localeFile, res.Content, d.local)
 		if err != nil {
 			return fmt.Errorf("%s for %s", err, localeFile.Path)
-		} else {
-			sharedMessage("pull", localeFile)
 		}
-		if Debug {
-			fmt.Fprintln(os.Stderr, strings.Repeat("-", 10))
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(localeFile.Path), target.DirMode)
+	if err != nil {
+		return err
+	}
+
+	if p.hooks != nil {
+		if err := p.hooks.BeforePullFile.run("before_pull_file", localeFileEnv(localeFile), &d.hookOutput); err != nil {
+			return err
 		}
 	}
 
+	var validators *phraseapp.Validators
+	if p.cache != nil {
+		validators = p.cache.validators(target, localeFile)
+	}
+
+	var res *phraseapp.LocaleDownloadResponse
+	err = rateLimit.do(func() (err error) {
+		res, err = target.Download(p.client, localeFile, validators)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s for %s", err, localeFile.Path)
+	}
+
+	// the local file is still the one of the last pull
+	if res.NotModified {
+		d.notModified = true
+		return nil
+	}
+
+	local, err := target.readLocal(localeFile)
+	if err != nil {
+		return err
+	}
+	var content []byte
+	content, d.kept, err = target.process(localeFile, res.Content, local)
+	if err != nil {
+		return fmt.Errorf("%s for %s", err, localeFile.Path)
+	}
+
+	if p.snapshot != nil {
+		if err := p.snapshot.add(localeFile.Path, content); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFileAtomic(localeFile.Path, content, target.FileMode); err != nil {
+		return err
+	}
+
+	if p.hooks != nil {
+		if err := p.hooks.AfterPullFile.run("after_pull_file", localeFileEnv(localeFile), &d.hookOutput); err != nil {
+			return err
+		}
+	}
+
+	// recorded after the hooks, as they may change the file
+	if p.cache != nil {
+		return p.cache.record(target, localeFile, res.Validators)
+	}
 	return nil
 }
 
-func (target *Target) DownloadAndWriteToFile(client *phraseapp.Client, localeFile *LocaleFile) error {
+func (target *Target) downloadParams(localeFile *LocaleFile) *phraseapp.LocaleDownloadParams {
 	downloadParams := new(phraseapp.LocaleDownloadParams)
 	if target.Params != nil {
 		*downloadParams = target.Params.LocaleDownloadParams
@@ -166,6 +635,13 @@ func (target *Target) DownloadAndWriteToFile(client *phraseapp.Client, localeFil
 	if downloadParams.FileFormat == nil {
 		downloadParams.FileFormat = &localeFile.FileFormat
 	}
+	return downloadParams
+}
+
+// Downloads the locale file from PhraseApp. With validators of a previous
+// download the content is only returned if the locale changed since.
+func (target *Target) Download(client *phraseapp.Client, localeFile *LocaleFile, validators *phraseapp.Validators) (*phraseapp.LocaleDownloadResponse, error) {
+	downloadParams := target.downloadParams(localeFile)
 
 	if Debug {
 		fmt.Fprintln(os.Stderr, "Target file pattern:", target.File)
@@ -180,57 +656,119 @@ func (target *Target) DownloadAndWriteToFile(client *phraseapp.Client, localeFil
 		fmt.Fprintln(os.Stderr, "FormatOptions", downloadParams.FormatOptions)
 	}
 
-	res, err := client.LocaleDownload(target.ProjectID, localeFile.ID, downloadParams)
-	if err != nil {
-		return err
+	return client.LocaleDownloadIfModified(target.ProjectID, localeFile.ID, downloadParams, validators)
+}
+
+// Returns the content of the local file if the target merges into it, or
+// nil if it doesn't or the file doesn't exist yet.
+func (target *Target) readLocal(localeFile *LocaleFile) ([]byte, error) {
+	if !target.Merge {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(localeFile.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
 	}
+	return content, err
+}
 
-	err = ioutil.WriteFile(localeFile.Path, res, 0700)
-	if err != nil {
-		return err
+// Turns downloaded content into the content of the locale file. It is
+// merged into the local content if the target merges, and post processed.
+// Returns the local-only keys kept by the merge.
+func (target *Target) process(localeFile *LocaleFile, content, local []byte) ([]byte, []string, error) {
+	format := *target.downloadParams(localeFile).FileFormat
+
+	var kept []string
+	if target.Merge && local != nil {
+		var err error
+		if content, kept, err = mergeContent(format, local, content, target.PreferLocal); err != nil {
+			return nil, nil, err
+		}
 	}
-	return nil
+
+	content, err := target.Postprocess.apply(format, content)
+	return content, kept, err
+}
+
+// Returns the parameters the content of a downloaded locale file depends on.
+func (target *Target) contentParams(localeFile *LocaleFile) string {
+	params := formatParams(target.downloadParams(localeFile))
+	if target.Postprocess != nil {
+		params += " postprocess " + formatParams(target.Postprocess)
+	}
+	if target.Merge {
+		params += fmt.Sprintf(" merge prefer_local=%t", target.PreferLocal)
+	}
+	return params
 }
 
 func (target *Target) LocaleFiles() (LocaleFiles, error) {
 	localeID := target.GetLocaleID()
+	mappedCodes, err := target.LocaleMapping.localCodes(target.RemoteLocales)
+	if err != nil {
+		return nil, err
+	}
+
 	files := []*LocaleFile{}
 	for _, remoteLocale := range target.RemoteLocales {
 		if localeID != "" && !(remoteLocale.ID == localeID || remoteLocale.Name == localeID) {
 			continue
 		}
-		err := target.IsValidLocale(remoteLocale, target.File)
-		if err != nil {
-			return nil, err
-		}
 
-		localeFile := &LocaleFile{
-			Name:       remoteLocale.Name,
-			ID:         remoteLocale.ID,
-			Code:       remoteLocale.Code,
-			Tag:        target.GetTag(),
-			FileFormat: target.GetFormat(),
-			Path:       target.File,
+		// Locales listed in the locale mapping are written once per mapped
+		// local code, all others use the code of the remote locale.
+		codes := mappedCodes[remoteLocale.ID]
+		if len(codes) == 0 {
+			err := target.IsValidLocale(remoteLocale, target.File)
+			if err != nil {
+				return nil, err
+			}
+			codes = []string{remoteLocale.Code}
 		}
 
-		absPath, err := target.ReplacePlaceholders(localeFile)
-		if err != nil {
-			return nil, err
-		}
-		localeFile.Path = absPath
+		for _, code := range codes {
+			if !target.selectsLocale(code, remoteLocale) {
+				continue
+			}
 
-		files = append(files, localeFile)
+			localeFile := &LocaleFile{
+				Name:       remoteLocale.Name,
+				ID:         remoteLocale.ID,
+				Code:       code,
+				Tag:        target.GetTag(),
+				FileFormat: target.GetFormat(),
+				Path:       target.File,
+			}
+
+			absPath, err := target.ReplacePlaceholders(localeFile)
+			if err != nil {
+				return nil, err
+			}
+			localeFile.Path = absPath
+
+			files = append(files, localeFile)
+		}
 	}
 
 	return files, nil
 }
 
+// Reports whether the remote locale, written as the local code, is selected
+// by the locales and exclude_locales of the target.
+func (target *Target) selectsLocale(code string, remoteLocale *phraseapp.Locale) bool {
+	ids := []string{code, remoteLocale.Code, remoteLocale.Name, remoteLocale.ID}
+	if !selectsLocale(target.Locales, ids...) {
+		return false
+	}
+	return len(target.ExcludeLocales) == 0 || !selectsLocale(target.ExcludeLocales, ids...)
+}
+
 func (target *Target) IsValidLocale(locale *phraseapp.Locale, localPath string) error {
 	if locale == nil {
 		return fmt.Errorf("Remote locale could not be downloaded correctly!")
 	}
 
-	if strings.Contains(localPath, "<locale_code>") && locale.Code == "" {
+	if usesLocaleCode(localPath) && locale.Code == "" {
 		return fmt.Errorf("Locale code is not set for Locale with ID: %s but locale_code is used in file name", locale.ID)
 	}
 	return nil
@@ -242,11 +780,7 @@ func (target *Target) ReplacePlaceholders(localeFile *LocaleFile) (string, error
 		return "", err
 	}
 
-	path := strings.Replace(absPath, "<locale_name>", localeFile.Name, -1)
-	path = strings.Replace(path, "<locale_code>", localeFile.Code, -1)
-	path = strings.Replace(path, "<tag>", localeFile.Tag, -1)
-
-	return path, nil
+	return replacePlaceholders(absPath, localeFile), nil
 }
 
 func (t *Target) GetFormat() string {
@@ -279,7 +813,11 @@ func TargetsFromConfig(cmd *PullCommand) (Targets, error) {
 	}
 
 	tmp := struct {
-		Targets Targets
+		Targets     Targets
+		Concurrency int
+		Snapshots   *int
+		FileMode    *fileMode `yaml:"file_mode"`
+		DirMode     *fileMode `yaml:"dir_mode"`
 	}{}
 	err := yaml.Unmarshal(cmd.Config.Targets, &tmp)
 	if err != nil {
@@ -287,13 +825,44 @@ func TargetsFromConfig(cmd *PullCommand) (Targets, error) {
 	}
 	tgts := tmp.Targets
 
+	if cmd.Parallel == 0 {
+		cmd.Parallel = tmp.Concurrency
+	}
+
+	cmd.snapshots = defaultSnapshots
+	if tmp.Snapshots != nil {
+		if *tmp.Snapshots < 0 {
+			return nil, fmt.Errorf("pull.snapshots must not be negative, got %d", *tmp.Snapshots)
+		}
+		cmd.snapshots = *tmp.Snapshots
+	}
+
 	token := cmd.Credentials.Token
 	projectId := cmd.Config.DefaultProjectID
 	fileFormat := cmd.Config.DefaultFileFormat
 
+	fileMode, dirMode := defaultFileMode, defaultDirMode
+	if tmp.FileMode != nil {
+		fileMode = os.FileMode(*tmp.FileMode)
+	}
+	if tmp.DirMode != nil {
+		dirMode = os.FileMode(*tmp.DirMode)
+	}
+
+	names := []string{}
+	for _, target := range tgts {
+		if target != nil {
+			names = append(names, target.Name)
+		}
+	}
+	sel := &selection{only: cmd.Only, except: cmd.Except}
+	if err := sel.check("target", names); err != nil {
+		return nil, err
+	}
+
 	validTargets := []*Target{}
 	for _, target := range tgts {
-		if target == nil {
+		if target == nil || !sel.includes(target.Name) {
 			continue
 		}
 		if target.ProjectID == "" {
@@ -302,6 +871,27 @@ func TargetsFromConfig(cmd *PullCommand) (Targets, error) {
 		if target.AccessToken == "" {
 			target.AccessToken = token
 		}
+		if target.FileMode == 0 {
+			target.FileMode = fileMode
+		}
+		if target.DirMode == 0 {
+			target.DirMode = dirMode
+		}
+		target.LocaleMapping = cmd.clientConfig.LocaleMapping
+		target.PreferLocal = cmd.PreferLocal
+		if len(cmd.Locales) > 0 {
+			target.Locales, target.ExcludeLocales = cmd.Locales, nil
+		}
+		if cmd.Branch != "" {
+			if target.Params == nil {
+				target.Params = new(PullParams)
+			}
+			if target.Params.Tag != nil {
+				return nil, fmt.Errorf("--branch can't be combined with params.tag of the target %s", target.File)
+			}
+			tag := normalizeBranch(cmd.Branch)
+			target.Params.Tag = &tag
+		}
 		if target.FileFormat == "" {
 			target.FileFormat = fileFormat
 		}
@@ -314,21 +904,3 @@ func TargetsFromConfig(cmd *PullCommand) (Targets, error) {
 
 	return validTargets, nil
 }
-
-func createFile(path string) error {
-	err := Exists(path)
-	if err != nil {
-		absDir := filepath.Dir(path)
-		err := Exists(absDir)
-		if err != nil {
-			os.MkdirAll(absDir, 0700)
-		}
-
-		f, err := os.Create(path)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-	}
-	return nil
-}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// Targets with a <tag> placeholder, but without params.tag, are pulled once
+// per tag of the project, limited to the tags listed in `tags` and matching
+// `tag_pattern` if given:
+//
+//	file: ./locales/<tag>/<locale_code>.json
+//	tag_pattern: ^feature-
+func (target *Target) expandsTags() bool {
+	return usesPlaceholder(target.File, "tag") && target.GetTag() == ""
+}
+
+func (target *Target) checkTags() error {
+	if (len(target.Tags) > 0 || target.TagPattern != "") && !usesPlaceholder(target.File, "tag") {
+		return fmt.Errorf("tags and tag_pattern require a <tag> placeholder in the file pattern %s", target.File)
+	}
+	if _, err := regexp.Compile(target.TagPattern); err != nil {
+		return fmt.Errorf("invalid tag_pattern: %s", err)
+	}
+	return nil
+}
+
+// Returns a copy of the target for every selected tag of the project, sorted
+// by tag name.
+func (target *Target) perTag(client *phraseapp.Client) ([]*Target, error) {
+	var tags []*phraseapp.Tag
+	err := rateLimit.do(func() (err error) {
+		tags, err = RemoteTags(client, target.ProjectID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	for _, name := range target.Tags {
+		if !Contains(names, name) {
+			return nil, fmt.Errorf("there is no tag %q in project %s", name, target.ProjectID)
+		}
+	}
+
+	pattern, err := regexp.Compile(target.TagPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag_pattern: %s", err)
+	}
+	sort.Strings(names)
+	targets := []*Target{}
+	for _, name := range names {
+		if len(target.Tags) > 0 && !Contains(target.Tags, name) || !pattern.MatchString(name) {
+			continue
+		}
+		targets = append(targets, target.withTag(name))
+	}
+	return targets, nil
+}
+
+func (target *Target) withTag(tag string) *Target {
+	t := *target
+	t.Params = new(PullParams)
+	if target.Params != nil {
+		*t.Params = *target.Params
+	}
+	t.Params.Tag = &tag
+	return &t
+}
+
+func RemoteTags(client *phraseapp.Client, projectId string) ([]*phraseapp.Tag, error) {
+	result := []*phraseapp.Tag{}
+	for page := 1; ; page++ {
+		tags, err := client.TagsList(projectId, page, 100)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tags...)
+		if len(tags) < 100 {
+			return result, nil
+		}
+	}
+}
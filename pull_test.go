@@ -1,9 +1,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
 )
 
 func getBaseTarget() *Target {
@@ -122,3 +128,504 @@ func TestReplacePlaceholders(t *testing.T) {
 		t.Errorf("Expected the new path to eql '%s' and not %s", "/en/abc/english.yml", newPath)
 	}
 }
+
+func TestTargetLocaleFilesWithMapping(t *testing.T) {
+	target := getBaseTarget()
+	target.LocaleMapping = LocaleMapping{"en_US": "english", "en_GB": "en-locale-id"}
+
+	localeFiles, err := target.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	paths := []string{}
+	for _, lf := range localeFiles {
+		paths = append(paths, filepath.Base(lf.Path))
+		if strings.HasPrefix(lf.Code, "en") && lf.ID != "en-locale-id" {
+			t.Errorf("expected %s to be downloaded from locale en-locale-id, got %s", lf.Path, lf.ID)
+		}
+	}
+
+	if strings.Join(paths, ",") != "en_GB.yml,en_US.yml,de.yml" {
+		t.Errorf("unexpected locale files %v", paths)
+	}
+}
+
+func TestTargetLocaleFilesResolvesMappingPerProject(t *testing.T) {
+	target := getBaseTarget()
+	target.RemoteLocales = append(target.RemoteLocales, &phraseapp.Locale{Code: "de", ID: "de-at-locale-id", Name: "austrian"})
+
+	target.LocaleMapping = LocaleMapping{"de_DE": "de"}
+	_, err := target.LocaleFiles()
+	if err == nil {
+		t.Fatalf("expected an error for an ambiguous mapping")
+	}
+	for _, id := range []string{"de-locale-id", "de-at-locale-id"} {
+		if !strings.Contains(err.Error(), id) {
+			t.Errorf("expected error to list candidate %q, got: %s", id, err)
+		}
+	}
+
+	// the mapping is shared by all targets, so locales of other projects are
+	// ignored
+	target.LocaleMapping = LocaleMapping{"en_US": "english", "xx": "other-project-locale-id"}
+	localeFiles, err := target.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	paths := []string{}
+	for _, lf := range localeFiles {
+		paths = append(paths, filepath.Base(lf.Path))
+	}
+	if strings.Join(paths, ",") != "en_US.yml,de.yml,de.yml" {
+		t.Errorf("unexpected locale files %v", paths)
+	}
+}
+
+func TestTargetLocaleFilesLocaleFilter(t *testing.T) {
+	target := getBaseTarget()
+	target.Locales = []string{"de"}
+
+	localeFiles, err := target.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	if len(localeFiles) != 1 || localeFiles[0].Code != "de" {
+		t.Errorf("expected only the de locale file, got %v", localeFiles)
+	}
+}
+
+func TestTargetLocaleFilesExcludeLocales(t *testing.T) {
+	for _, tc := range []struct {
+		locales, exclude []string
+		expected         string
+	}{
+		{locales: []string{"german", "en-locale-id"}, expected: "en,de"},
+		{exclude: []string{"english"}, expected: "de"},
+		{locales: []string{"en", "de"}, exclude: []string{"de-locale-id"}, expected: "en"},
+	} {
+		target := getBaseTarget()
+		target.Locales, target.ExcludeLocales = tc.locales, tc.exclude
+
+		localeFiles, err := target.LocaleFiles()
+		if err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+		codes := []string{}
+		for _, localeFile := range localeFiles {
+			codes = append(codes, localeFile.Code)
+		}
+		if strings.Join(codes, ",") != tc.expected {
+			t.Errorf("expected locales %s for %v without %v, got %v", tc.expected, tc.locales, tc.exclude, codes)
+		}
+	}
+}
+
+func TestTargetsFromConfigLocales(t *testing.T) {
+	cmd := &PullCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Targets = []byte(`
+targets:
+- file: ./<locale_code>.yml
+  locales: [en, de]
+  exclude_locales:
+  - de
+`)
+
+	targets, err := TargetsFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if target := targets[0]; strings.Join(target.Locales, ",") != "en,de" || strings.Join(target.ExcludeLocales, ",") != "de" {
+		t.Errorf("expected the configured locales, got %v and %v", target.Locales, target.ExcludeLocales)
+	}
+
+	// --locale overrides the configured locales
+	cmd.Locales = []string{"fr"}
+	targets, err = TargetsFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if target := targets[0]; strings.Join(target.Locales, ",") != "fr" || target.ExcludeLocales != nil {
+		t.Errorf("expected the locales of the command, got %v and %v", target.Locales, target.ExcludeLocales)
+	}
+}
+
+func TestTargetsFromConfigSelection(t *testing.T) {
+	cmd := &PullCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Targets = []byte(`
+targets:
+- name: web
+  file: ./web/<locale_code>.yml
+- name: mobile
+  file: ./mobile/<locale_code>.yml
+`)
+	cmd.Except = []string{"web"}
+
+	targets, err := TargetsFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if len(targets) != 1 || targets[0].Name != "mobile" {
+		t.Errorf("expected only the mobile target, got %v", targets)
+	}
+
+	cmd.Except = nil
+	cmd.Only = []string{"desktop"}
+	if _, err := TargetsFromConfig(cmd); err == nil || err.Error() != `there is no target named "desktop"` {
+		t.Errorf("expected unknown target error, got: %v", err)
+	}
+}
+
+func TestTargetsFromConfigFileModes(t *testing.T) {
+	cmd := &PullCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Targets = []byte(`
+file_mode: 0640
+targets:
+- name: web
+  file: ./web/<locale_code>.yml
+- name: mobile
+  file: ./mobile/<locale_code>.yml
+  file_mode: 600
+  dir_mode: "0700"
+`)
+
+	targets, err := TargetsFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	for i, expected := range [][2]os.FileMode{{0640, 0755}, {0600, 0700}} {
+		if targets[i].FileMode != expected[0] || targets[i].DirMode != expected[1] {
+			t.Errorf("expected modes %o and %o for %s, got %o and %o", expected[0], expected[1], targets[i].Name, targets[i].FileMode, targets[i].DirMode)
+		}
+	}
+
+	cmd.Config.Targets = []byte(`
+targets:
+- file: ./web/<locale_code>.yml
+  file_mode: 0999
+`)
+	if _, err := TargetsFromConfig(cmd); err == nil || !strings.Contains(err.Error(), `invalid mode "0999"`) {
+		t.Errorf("expected invalid mode error, got: %v", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := setupFiles(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "en.yml")
+	if err := writeFileAtomic(path, []byte("a: b\n"), 0640); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("expected a new file with mode 0640, got %v (%v)", info, err)
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("a: c\n"), 0644); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the existing file to keep mode 0600, got %v (%v)", info, err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "a: c\n" {
+		t.Errorf("expected the new content, got %q", content)
+	}
+
+	names, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("expected no temporary files to be left, got %d files", len(names))
+	}
+}
+
+func TestPullDownloads(t *testing.T) {
+	dir := setupFiles(t)
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		id := strings.Split(req.URL.Path, "/")[5]
+		if strings.HasPrefix(id, "missing") {
+			resp.WriteHeader(http.StatusNotFound)
+			return
+		}
+		resp.Write([]byte(id))
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	target := getBaseTarget()
+	target.FileMode, target.DirMode = defaultFileMode, defaultDirMode
+	downloads := []*download{}
+	for _, id := range []string{"en", "missing-de", "fr", "missing-it"} {
+		localeFile := &LocaleFile{ID: id, Path: filepath.Join(dir, "locales", id+".yml")}
+		downloads = append(downloads, &download{target: target, localeFile: localeFile})
+	}
+
+	p := &puller{client: c, hooks: new(Hooks)}
+	err := p.pull(downloads, 3)
+	if err == nil || err.Error() != "2 of 4 downloads failed" {
+		t.Errorf("expected both failures to be reported, got: %v", err)
+	}
+	for _, id := range []string{"en", "fr"} {
+		if content, err := ioutil.ReadFile(filepath.Join(dir, "locales", id+".yml")); err != nil || string(content) != id {
+			t.Errorf("expected %s to be downloaded, got %q (%v)", id, content, err)
+		}
+	}
+
+	err = p.pull(downloads[1:2], 3)
+	if err == nil || !strings.HasSuffix(err.Error(), "for "+downloads[1].localeFile.Path) {
+		t.Errorf("expected the download error of the file, got: %v", err)
+	}
+}
+
+func TestPullHookFailureAborts(t *testing.T) {
+	dir := setupFiles(t)
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		id := strings.Split(req.URL.Path, "/")[5]
+		if strings.HasPrefix(id, "missing") {
+			resp.WriteHeader(http.StatusNotFound)
+			return
+		}
+		resp.Write([]byte(id))
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	target := getBaseTarget()
+	target.FileMode, target.DirMode = defaultFileMode, defaultDirMode
+	downloads := []*download{}
+	for _, id := range []string{"missing-en", "missing-de", "fr", "it", "es"} {
+		localeFile := &LocaleFile{ID: id, Path: filepath.Join(dir, "locales", id+".yml")}
+		downloads = append(downloads, &download{target: target, localeFile: localeFile})
+	}
+
+	p := &puller{client: c, hooks: &Hooks{AfterPullFile: hookCommands{`test "$PHRASEAPP_LOCALE_ID" != fr || exit 4`}}}
+	err := p.pull(downloads, 1)
+	if herr, ok := err.(*hookError); !ok || herr.status != 4 {
+		t.Fatalf("expected the hook error with exit status 4, got: %v", err)
+	}
+	for _, id := range []string{"it", "es"} {
+		if _, err := os.Stat(filepath.Join(dir, "locales", id+".yml")); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to be downloaded after the failed hook, got %v", id, err)
+		}
+	}
+}
+
+func TestPullCheck(t *testing.T) {
+	dir := setupFiles(t)
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Write([]byte("a: " + strings.Split(req.URL.Path, "/")[5] + "\n"))
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	for name, content := range map[string]string{"en": "a: en\n", "de": "a: old\n"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".yml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := getBaseTarget()
+	downloads := []*download{}
+	for _, id := range []string{"en", "de", "fr"} {
+		localeFile := &LocaleFile{ID: id, Path: filepath.Join(dir, id+".yml")}
+		downloads = append(downloads, &download{target: target, localeFile: localeFile})
+	}
+
+	p := &puller{client: c, check: true}
+	err := p.pull(downloads, 2)
+	if err == nil || err.Error() != "2 of 3 files are out of date with PhraseApp" {
+		t.Errorf("expected the out of date files to be reported, got: %v", err)
+	}
+	if !downloads[0].upToDate() || downloads[1].upToDate() || !downloads[2].missing {
+		t.Errorf("expected en to be up to date, de to differ and fr to be missing")
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "de.yml")); string(content) != "a: old\n" {
+		t.Errorf("expected the local file to be unchanged, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fr.yml")); !os.IsNotExist(err) {
+		t.Errorf("expected no file to be created, got: %v", err)
+	}
+}
+
+func TestPullDownloadCache(t *testing.T) {
+	dir := setupFiles(t)
+	defer os.RemoveAll(dir)
+
+	requests, conditional := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			resp.WriteHeader(http.StatusNotModified)
+			return
+		}
+		resp.Header().Set("ETag", `"v1"`)
+		resp.Write([]byte("a: b\n"))
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	target := getBaseTarget()
+	target.FileMode, target.DirMode = defaultFileMode, defaultDirMode
+	path := filepath.Join(dir, "en.yml")
+	d := &download{target: target, localeFile: &LocaleFile{ID: "en", Path: path}}
+
+	cache, err := loadDownloadCache(filepath.Join(dir, ".phraseapp", "cache.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &puller{client: c, cache: cache}
+
+	if err := p.pull([]*download{d}, 1); err != nil || d.notModified {
+		t.Fatalf("expected the file to be downloaded, got: %v", err)
+	}
+	if err := p.pull([]*download{d}, 1); err != nil || !d.notModified {
+		t.Errorf("expected the file not to be modified, got: %v", err)
+	}
+
+	// a changed local file is downloaded again
+	if err := ioutil.WriteFile(path, []byte("a: c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d.notModified = false
+	if err := p.pull([]*download{d}, 1); err != nil || d.notModified {
+		t.Errorf("expected the changed file to be downloaded, got: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "a: b\n" {
+		t.Errorf("expected the downloaded content, got %q", content)
+	}
+	if requests != 3 || conditional != 1 {
+		t.Errorf("expected 3 requests with 1 conditional one, got %d and %d", requests, conditional)
+	}
+
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadDownloadCache(cache.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := loaded.validators(target, d.localeFile); v == nil || v.ETag != `"v1"` {
+		t.Errorf("expected the saved validators, got %v", v)
+	}
+}
+
+func TestTargetPerTag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Write([]byte(`[{"name": "feature-search"}, {"name": "shared"}, {"name": "feature-checkout"}]`))
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	target := getBaseTarget()
+	target.File = "./locales/<tag>/<locale_code>.json"
+	if !target.expandsTags() {
+		t.Fatalf("expected a target with a <tag> placeholder to be expanded")
+	}
+
+	for _, tc := range []struct {
+		tags     []string
+		pattern  string
+		expected string
+	}{
+		{expected: "feature-checkout,feature-search,shared"},
+		{pattern: "^feature-", expected: "feature-checkout,feature-search"},
+		{tags: []string{"shared", "feature-search"}, pattern: "search", expected: "feature-search"},
+	} {
+		target.Tags, target.TagPattern = tc.tags, tc.pattern
+		targets, err := target.perTag(c)
+		if err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+		tags := []string{}
+		for _, tgt := range targets {
+			tags = append(tags, tgt.GetTag())
+		}
+		if strings.Join(tags, ",") != tc.expected {
+			t.Errorf("expected tags %s for %v and %q, got %v", tc.expected, tc.tags, tc.pattern, tags)
+		}
+	}
+
+	if target.GetTag() != "" {
+		t.Errorf("expected the tag of the original target to be unchanged, got %q", target.GetTag())
+	}
+
+	target.Tags = []string{"missing"}
+	if _, err := target.perTag(c); err == nil || err.Error() != `there is no tag "missing" in project project-id` {
+		t.Errorf("expected an error for a missing tag, got: %v", err)
+	}
+
+	target.Tags, target.TagPattern = nil, "(feature"
+	if _, err := target.perTag(c); err == nil {
+		t.Errorf("expected an error for an invalid tag_pattern")
+	}
+	if err := target.CheckPreconditions(); err == nil {
+		t.Errorf("expected an error for an invalid tag_pattern")
+	}
+
+	target.File, target.Tags, target.TagPattern = "./locales/<locale_code>.json", []string{"feature-a"}, ""
+	if err := target.CheckPreconditions(); err == nil {
+		t.Errorf("expected an error for tags without a <tag> placeholder")
+	}
+}
+
+func TestCheckDistinctPathsOfLossyPlaceholders(t *testing.T) {
+	target := getBaseTarget()
+	target.File = "./values-<language>/strings.xml"
+	target.RemoteLocales = append(target.RemoteLocales, &phraseapp.Locale{Code: "en-GB", ID: "en-gb-locale-id", Name: "british"})
+
+	localeFiles, err := target.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	downloads := []*download{}
+	for _, lf := range localeFiles {
+		downloads = append(downloads, &download{target: target, localeFile: lf})
+	}
+
+	err = checkDistinctPaths(downloads)
+	if err == nil {
+		t.Fatalf("expected an error for locales written to the same file")
+	}
+	for _, part := range []string{"values-en/strings.xml would be written by several locales", "en-locale-id", "en-gb-locale-id"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("expected error to contain %q, got: %s", part, err)
+		}
+	}
+	if strings.Contains(err.Error(), "de-locale-id") {
+		t.Errorf("expected only the conflicting locales to be listed, got: %s", err)
+	}
+
+	target.ExcludeLocales = []string{"en-GB"}
+	if localeFiles, err = target.LocaleFiles(); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	downloads = downloads[:0]
+	for _, lf := range localeFiles {
+		downloads = append(downloads, &download{target: target, localeFile: lf})
+	}
+	if err := checkDistinctPaths(downloads); err != nil {
+		t.Errorf("didn't expect an error, got: %s", err)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
 
@@ -16,6 +19,21 @@ import (
 
 type PushCommand struct {
 	*phraseapp.Config
+
+	DryRun       bool     `cli:"opt --dry-run desc='Print the upload plan without uploading anything'"`
+	Parallel     int      `cli:"opt --parallel desc='Number of concurrent uploads (default: push.concurrency or 1)'"`
+	Wait         bool     `cli:"opt --wait desc='Wait for uploads to be processed and print a summary'"`
+	WaitTimeout  int      `cli:"opt --wait-timeout desc='Seconds to wait for an upload to be processed' default=300"`
+	Force        bool     `cli:"opt --force desc='Upload all files, even if unchanged since the last push'"`
+	Only         []string `cli:"opt --only desc='Push only the sources with these names (comma separated)'"`
+	Except       []string `cli:"opt --except desc='Skip the sources with these names (comma separated)'"`
+	Locales      []string `cli:"opt --locale desc='Push only the files of these locale codes (comma separated)'"`
+	ChangedSince string   `cli:"opt --changed-since desc='Push only files changed since this git revision'"`
+	Cleanup      bool     `cli:"opt --cleanup desc='Delete remote keys missing from the pushed files (implies --force and --wait)'"`
+	Yes          bool     `cli:"opt --yes desc='Delete keys without confirmation on --cleanup'"`
+	Branch       string   `cli:"opt --branch desc='Git branch for branch_tag and <branch> (default: the checked out branch)'"`
+
+	clientConfig clientConfig
 }
 
 func (cmd *PushCommand) Run() error {
@@ -25,6 +43,33 @@ func (cmd *PushCommand) Run() error {
 		Debug = true
 	}
 
+	if cmd.Cleanup {
+		if cmd.ChangedSince != "" || len(cmd.Locales) > 0 {
+			return fmt.Errorf("--cleanup can't be combined with --changed-since or --locale, all files of a source must be pushed")
+		}
+		// keys of unchanged files must be tagged by the cleanup uploads as well
+		cmd.Force = true
+		cmd.Wait = true
+	}
+
+	if cmd.Wait && cmd.WaitTimeout <= 0 {
+		return fmt.Errorf("--wait-timeout must be a positive number of seconds, got %d", cmd.WaitTimeout)
+	}
+
+	hooks, err := HooksFromConfig(&cmd.clientConfig)
+	if err != nil {
+		return err
+	}
+
+	// a dry run doesn't change anything, so no hooks are run
+	if cmd.DryRun {
+		hooks = new(Hooks)
+	}
+
+	if err := hooks.BeforePush.run("before_push", nil, nil); err != nil {
+		return err
+	}
+
 	client, err := newClient(cmd.Config.Credentials)
 	if err != nil {
 		return err
@@ -42,42 +87,166 @@ func (cmd *PushCommand) Run() error {
 		}
 	}
 
+	lock, err := loadLockfile(lockfileName)
+	if err != nil {
+		return err
+	}
+
+	var changes *gitChanges
+	if cmd.ChangedSince != "" {
+		changes, err = changedSince(cmd.ChangedSince)
+		if err != nil {
+			return err
+		}
+	}
+
+	uploads, invalid := []*upload{}, []error{}
 	for _, source := range sources {
+		localeFiles, err := source.resolveLocaleFiles(client)
+		if err != nil {
+			return err
+		}
+
+		if changes != nil {
+			localeFiles = changes.filter(localeFiles)
+		}
+
+		if !cmd.Force {
+			localeFiles = lock.changed(client, source, localeFiles)
+		}
+
+		paths := []string{}
+		for _, localeFile := range localeFiles {
+			paths = append(paths, localeFile.RelPath())
+		}
+		errs, err := source.checkSyntax(paths)
+		if err != nil {
+			return err
+		}
+		invalid = append(invalid, errs...)
+
+		if cmd.DryRun {
+			source.printPlan(os.Stdout, localeFiles)
+			continue
+		}
+
+		for _, localeFile := range localeFiles {
+			uploads = append(uploads, &upload{source: source, localeFile: localeFile})
+		}
+	}
+
+	if err := reportSyntaxErrors(invalid); err != nil {
+		return fmt.Errorf("%s, nothing was uploaded", err)
+	}
 
-		err := source.Push(client)
+	if cmd.DryRun {
+		return nil
+	}
+
+	var c *cleaner
+	var scopes []*cleanupScope
+	if cmd.Cleanup {
+		scopes, err = cleanupScopes(uploads, len(cmd.Only) == 0 && len(cmd.Except) == 0)
 		if err != nil {
 			return err
 		}
+
+		c = &cleaner{client: client, tag: newCleanupTag(), out: os.Stdout}
+		if !cmd.Yes {
+			c.confirm = confirmOnStdin
+		}
+		for _, source := range sources {
+			source.cleanupTag = c.tag
+		}
 	}
-	return nil
+
+	p := newPusher(client)
+	p.lock = lock
+	p.hooks = hooks
+	if cmd.Wait {
+		p.waitTimeout = time.Duration(cmd.WaitTimeout) * time.Second
+	}
+
+	// files uploaded successfully are recorded even if others failed
+	err = p.push(uploads, cmd.Parallel)
+	if saveErr := lock.save(); err == nil {
+		err = saveErr
+	}
+	if c != nil {
+		cleanupErr := c.finish(scopes, uploads, err == nil && allUploadsProcessed(uploads))
+		if err == nil {
+			err = cleanupErr
+		} else if cleanupErr != nil {
+			fmt.Fprintln(os.Stderr, cleanupErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return hooks.AfterPush.run("after_push", nil, nil)
 }
 
 type Sources []*Source
 
 type Source struct {
+	Name        string
 	File        string
 	ProjectID   string
 	AccessToken string
 	FileFormat  string
 	Params      *phraseapp.UploadParams
+	Excludes    []string
+	BranchTag   bool
+
+	LocaleDefaults *LocaleDefaults
 
 	RemoteLocales []*phraseapp.Locale
 	Format        *phraseapp.Format
+	LocaleMapping LocaleMapping
+
+	// Locales limits the source to the files of these locale codes.
+	Locales []string
+
+	// Branch is the normalized name of the current git branch, if the
+	// source refers to it.
+	Branch string
+
+	// cleanupTag is added to all uploads of push --cleanup, but not recorded
+	// in the lockfile.
+	cleanupTag string
 }
 
 func (src *Source) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	m := map[string]interface{}{}
+	localeDefaults := map[string]interface{}{}
+	var excludes []byte
 	err := phraseapp.ParseYAMLToMap(unmarshal, map[string]interface{}{
-		"file":         &src.File,
-		"project_id":   &src.ProjectID,
-		"access_token": &src.AccessToken,
-		"file_format":  &src.FileFormat,
-		"params":       &m,
+		"name":            &src.Name,
+		"file":            &src.File,
+		"project_id":      &src.ProjectID,
+		"access_token":    &src.AccessToken,
+		"file_format":     &src.FileFormat,
+		"params":          &m,
+		"excludes":        &excludes,
+		"branch_tag":      &src.BranchTag,
+		"locale_defaults": &localeDefaults,
 	})
 	if err != nil {
 		return err
 	}
 
+	src.LocaleDefaults, err = parseLocaleDefaults("locale_defaults", localeDefaults)
+	if err != nil {
+		return err
+	}
+
+	if excludes != nil {
+		if err := yaml.Unmarshal(excludes, &src.Excludes); err != nil {
+			return fmt.Errorf("configuration key \"excludes\" must be a list of file patterns: %s", err)
+		}
+	}
+
 	src.Params = new(phraseapp.UploadParams)
 	return src.Params.ApplyValuesFromMap(m)
 }
@@ -96,74 +265,195 @@ func (source *Source) CheckPreconditions() error {
 		}
 	}
 
-	starCount := strings.Count(source.File, "*")
-	recCount := strings.Count(source.File, "**")
-
-	// starCount contains the `**` so that must be taken into account.
-	if starCount-(recCount*2) > 1 {
-		duplicatedPlaceholders = append(duplicatedPlaceholders, "*")
+	if len(duplicatedPlaceholders) > 0 {
+		dups := strings.Join(duplicatedPlaceholders, ", ")
+		return fmt.Errorf(fmt.Sprintf("%s can only occur once in a file pattern!", dups))
 	}
 
-	if recCount > 1 {
-		duplicatedPlaceholders = append(duplicatedPlaceholders, "**")
+	if err := validatePlaceholders(source.File); err != nil {
+		return err
 	}
 
-	if len(duplicatedPlaceholders) > 0 {
-		dups := strings.Join(duplicatedPlaceholders, ", ")
-		return fmt.Errorf(fmt.Sprintf("%s can only occur once in a file pattern!", dups))
+	if _, err := compileGlob(source.File); err != nil {
+		return fmt.Errorf("invalid file pattern %q: %s", source.File, err)
 	}
 
 	return nil
 }
 
-func (source *Source) Push(client *phraseapp.Client) error {
+// Checks the source and returns its locale files matched against the
+// locales of the remote project.
+func (source *Source) resolveLocaleFiles(client *phraseapp.Client) (LocaleFiles, error) {
 	if err := source.CheckPreconditions(); err != nil {
-		return err
+		return nil, err
 	}
 
 	remoteLocales, err := RemoteLocales(client, source.ProjectID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	source.RemoteLocales = remoteLocales
 
-	localeFiles, err := source.LocaleFiles()
-	if err != nil {
-		return err
+	return source.LocaleFiles()
+}
+
+type upload struct {
+	source     *Source
+	localeFile *LocaleFile
+
+	createErr error
+	result    *phraseapp.Upload
+	waitErr   error
+
+	// the output of the per-file hooks, printed with the report of the file
+	hookOutput bytes.Buffer
+}
+
+// pusher uploads locale files of all sources using a bounded number of
+// workers. Locales are created at most once per project and code, even if
+// several sources refer to the same missing locale.
+type pusher struct {
+	client *phraseapp.Client
+
+	// waitTimeout is the time to wait for an upload to be processed. Uploads
+	// are only sent, but not awaited, if it is zero.
+	waitTimeout time.Duration
+
+	// lock records the successfully pushed files if set.
+	lock *Lockfile
+
+	// hooks are run before and after each upload if set.
+	hooks *Hooks
+
+	mutex          sync.Mutex
+	createdLocales map[string]*phraseapp.LocaleDetails
+}
+
+func newPusher(client *phraseapp.Client) *pusher {
+	return &pusher{
+		client:         client,
+		createdLocales: map[string]*phraseapp.LocaleDetails{},
+	}
+}
+
+func (p *pusher) push(uploads []*upload, workers int) error {
+	work := func(i int) error {
+		return p.upload(uploads[i])
 	}
 
-	for _, localeFile := range localeFiles {
-		fmt.Println("Uploading", localeFile.RelPath())
+	report := func(i int, err error) {
+		u := uploads[i]
+		fmt.Println("Uploading", u.localeFile.RelPath())
+		os.Stdout.Write(u.hookOutput.Bytes())
 
-		if localeFile.shouldCreateLocale(source) {
-			localeDetails, err := source.createLocale(client, localeFile)
-			if err == nil {
-				localeFile.ID = localeDetails.ID
-				localeFile.Code = localeDetails.Code
-				localeFile.Name = localeDetails.Name
-			} else {
-				fmt.Printf("failed to create locale: %s\n", err)
-				continue
-			}
+		switch {
+		case u.createErr != nil:
+			fmt.Printf("failed to create locale: %s\n", u.createErr)
+			return
+		case err != nil:
+			return
 		}
 
-		err = source.uploadFile(client, localeFile)
+		sharedMessage("push", u.localeFile)
+
+		if Debug {
+			fmt.Fprintln(os.Stderr, strings.Repeat("-", 10))
+		}
+	}
+
+	err := forEachParallel(len(uploads), workers, true, work, report)
+	if err != nil || p.waitTimeout == 0 {
+		return err
+	}
+
+	return printUploadSummary(os.Stdout, uploads)
+}
+
+func (p *pusher) upload(u *upload) error {
+	source, localeFile := u.source, u.localeFile
+
+	if localeFile.shouldCreateLocale(source) {
+		localeDetails, err := p.createLocale(source, localeFile)
 		if err != nil {
+			// the file is skipped, but the remaining uploads continue
+			u.createErr = err
+			return nil
+		}
+		localeFile.ID = localeDetails.ID
+		localeFile.Code = localeDetails.Code
+		localeFile.Name = localeDetails.Name
+	}
+
+	if p.hooks != nil {
+		if err := p.hooks.BeforePushFile.run("before_push_file", localeFileEnv(localeFile), &u.hookOutput); err != nil {
 			return err
 		}
+	}
 
-		sharedMessage("push", localeFile)
+	err := rateLimit.do(func() (err error) {
+		u.result, err = source.uploadFile(p.client, localeFile)
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-		if Debug {
-			fmt.Fprintln(os.Stderr, strings.Repeat("-", 10))
+	// the upload is checked on the next push if it isn't awaited
+	pendingID := u.result.ID
+	if p.waitTimeout > 0 {
+		// a failed or timed out import must not stop the remaining uploads,
+		// it is reported in the summary
+		u.result, u.waitErr = waitForUpload(p.client, source.ProjectID, u.result, p.waitTimeout)
+		if u.waitErr != nil || u.result.State != uploadStateSuccess {
+			return nil
 		}
+		pendingID = ""
+	}
 
+	if p.lock != nil {
+		if err := p.lock.record(source, localeFile, pendingID); err != nil {
+			return err
+		}
 	}
 
+	if p.hooks != nil {
+		return p.hooks.AfterPushFile.run("after_push_file", localeFileEnv(localeFile), &u.hookOutput)
+	}
 	return nil
 }
 
+func (p *pusher) createLocale(source *Source, localeFile *LocaleFile) (*phraseapp.LocaleDetails, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := source.ProjectID + "/" + localeFile.Code + "/" + localeFile.Name
+	if localeDetails, found := p.createdLocales[key]; found {
+		return localeDetails, nil
+	}
+
+	var localeDetails *phraseapp.LocaleDetails
+	err := rateLimit.do(func() (err error) {
+		localeDetails, err = source.createLocale(p.client, localeFile)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.createdLocales[key] = localeDetails
+	return localeDetails, nil
+}
+
 func (source *Source) createLocale(client *phraseapp.Client, localeFile *LocaleFile) (*phraseapp.LocaleDetails, error) {
+	localeDetails, err := client.LocaleCreate(source.ProjectID, source.localeParams(localeFile))
+	if err != nil {
+		return nil, err
+	}
+	return localeDetails, nil
+}
+
+// Parameters used to create the remote locale for the given locale file.
+func (source *Source) localeParams(localeFile *LocaleFile) *phraseapp.LocaleParams {
 	localeParams := new(phraseapp.LocaleParams)
 
 	if localeFile.Name != "" {
@@ -185,11 +475,8 @@ func (source *Source) createLocale(client *phraseapp.Client, localeFile *LocaleF
 		localeParams.Code = &localeFile.Code
 	}
 
-	localeDetails, err := client.LocaleCreate(source.ProjectID, localeParams)
-	if err != nil {
-		return nil, err
-	}
-	return localeDetails, nil
+	source.LocaleDefaults.apply(localeParams, localeFile.Code)
+	return localeParams
 }
 
 func (source *Source) replacePlaceholderInParams(localeFile *LocaleFile) string {
@@ -199,12 +486,21 @@ func (source *Source) replacePlaceholderInParams(localeFile *LocaleFile) string
 	return ""
 }
 
-func (source *Source) uploadFile(client *phraseapp.Client, localeFile *LocaleFile) error {
+func (source *Source) uploadFile(client *phraseapp.Client, localeFile *LocaleFile) (*phraseapp.Upload, error) {
 	if Debug {
 		fmt.Fprintln(os.Stdout, "Source file pattern:", source.File)
 		fmt.Fprintln(os.Stdout, "Actual file location:", localeFile.Path)
 	}
 
+	params := source.uploadParams(localeFile)
+	if source.cleanupTag != "" {
+		params.Tags = appendTag(params.Tags, source.cleanupTag)
+	}
+	return client.UploadCreate(source.ProjectID, params)
+}
+
+// Parameters sent to the uploads endpoint for the given locale file.
+func (source *Source) uploadParams(localeFile *LocaleFile) *phraseapp.UploadParams {
 	params := new(phraseapp.UploadParams)
 	*params = *source.Params
 
@@ -219,137 +515,34 @@ func (source *Source) uploadFile(client *phraseapp.Client, localeFile *LocaleFil
 		}
 	}
 
-	if localeFile.Tag != "" {
-		var v string
-		if params.Tags != nil {
-			v = *params.Tags + ","
-		}
-		v += localeFile.Tag
-		params.Tags = &v
-	}
-
-	_, err := client.UploadCreate(source.ProjectID, params)
-	return err
-}
-
-func (source *Source) SystemFiles() ([]string, error) {
-	pattern := placeholderRegexp.ReplaceAllString(source.File, "*")
-	parts := strings.SplitN(pattern, "**", 2)
-	var pre, post string
-
-	pre = parts[0]
-	// strip trailing path separators
-	for len(pre) > 0 && os.IsPathSeparator(pre[len(pre)-1]) {
-		pre = pre[0 : len(pre)-1]
-	}
-
-	if len(parts) == 2 {
-		post = parts[1]
-		for len(post) > 0 && os.IsPathSeparator(post[0]) {
-			post = post[1:]
-		}
-	}
-
-	candidates, err := filepath.Glob(pre)
-	if err != nil {
-		return nil, err
-	}
-
-	prefix := "." + string(os.PathSeparator)
-	if strings.HasPrefix(pre, prefix) {
-		pre = strings.TrimLeft(pre, prefix)
+	tags := []string{localeFile.Tag}
+	if source.BranchTag {
+		tags = append(tags, source.Branch)
 	}
-
-	var matches []string
-	if post != "" {
-		tokens := splitPathIntoSegments(strings.Replace(post, "*", ".*", -1))
-		tokenCountPre := len(splitPathIntoSegments(pre))
-
-		for _, cand := range candidates {
-			if !isDir(cand) {
-				continue
-			}
-
-			cands, err := findFilesInPath(cand)
-			if err != nil {
-				return nil, err
-			}
-
-			for _, c := range cands {
-				if validateFileCandidate(tokens, tokenCountPre, c) {
-					matches = append(matches, c)
-				}
-			}
+	for _, tag := range tags {
+		if tag != "" {
+			params.Tags = appendTag(params.Tags, tag)
 		}
-	} else {
-		matches = candidates
 	}
 
-	return matches, nil
+	return params
 }
 
-func isDir(path string) bool {
-	stat, err := os.Lstat(path)
-	if err != nil {
-		return false
+func appendTag(tags *string, tag string) *string {
+	var v string
+	if tags != nil {
+		v = *tags + ","
 	}
-	return stat.IsDir()
+	v += tag
+	return &v
 }
 
-func validateFileCandidate(tokens []string, ignoreTokenCnt int, cand string) bool {
-	candTokens := splitPathIntoSegments(cand)
-	candTokenCnt := len(candTokens)
-
-	if candTokenCnt < (len(tokens) + ignoreTokenCnt) {
-		return false
-	}
-
-	candTokens = candTokens[ignoreTokenCnt:]
-
-	for i := 1; i <= len(tokens); i++ {
-		expT, gotT := tokens[len(tokens)-i], candTokens[len(candTokens)-i]
-		switch {
-		case strings.Contains(expT, "*"):
-			matched, err := regexp.MatchString(expT, gotT)
-			if err != nil {
-				panic(err)
-			}
-			if !matched {
-				return false
-			}
-		case expT != gotT:
-			return false
-		}
-	}
-
-	return true
-}
-
-func splitPathIntoSegments(path string) []string {
-	segments := []string{}
-	start := 0
-	for i := range path {
-		if os.IsPathSeparator(path[i]) {
-			segments = append(segments, path[start:i])
-			start = i + 1
-		}
+func (source *Source) SystemFiles() ([]string, error) {
+	pattern, err := compileGlob(source.File)
+	if err != nil {
+		return nil, err
 	}
-	return append(segments, path[start:])
-}
-
-func findFilesInPath(root string) ([]string, error) {
-	files := []string{}
-	err := filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !f.Mode().IsDir() {
-			files = append(files, path)
-		}
-		return nil
-	})
-
-	return files, err
+	return pattern.files()
 }
 
 // Return all locale files from disk that match the source pattern.
@@ -359,6 +552,7 @@ func (source *Source) LocaleFiles() (LocaleFiles, error) {
 	if err != nil {
 		return nil, err
 	}
+	filePaths = source.withoutExcludedFiles(filePaths)
 
 	tokens := splitPathToTokens(source.File)
 
@@ -366,6 +560,7 @@ func (source *Source) LocaleFiles() (LocaleFiles, error) {
 	for _, path := range filePaths {
 		pathTokens := splitPathToTokens(path)
 		localeFile := extractParamsFromPathTokens(tokens, pathTokens)
+		localCode := localeFile.Code
 
 		absolutePath, err := filepath.Abs(path)
 		if err != nil {
@@ -374,7 +569,10 @@ func (source *Source) LocaleFiles() (LocaleFiles, error) {
 
 		localeFile.Path = absolutePath
 
-		locale := source.getRemoteLocaleForLocaleFile(localeFile)
+		locale, err := source.getRemoteLocaleForLocaleFile(localeFile)
+		if err != nil {
+			return nil, err
+		}
 		if locale != nil {
 			localeFile.ExistsRemote = true
 			localeFile.Code = locale.Code
@@ -382,6 +580,10 @@ func (source *Source) LocaleFiles() (LocaleFiles, error) {
 			localeFile.ID = locale.ID
 		}
 
+		if !selectsLocale(source.Locales, localCode, localeFile.Code) {
+			continue
+		}
+
 		if Debug {
 			fmt.Printf(
 				"Code:%q, Name:%q, ID:%q, Tag:%q\n",
@@ -392,7 +594,7 @@ func (source *Source) LocaleFiles() (LocaleFiles, error) {
 		localeFiles = append(localeFiles, localeFile)
 	}
 
-	if len(localeFiles) <= 0 {
+	if len(filePaths) <= 0 {
 		abs, err := filepath.Abs(source.File)
 		if err != nil {
 			abs = source.File
@@ -402,7 +604,36 @@ func (source *Source) LocaleFiles() (LocaleFiles, error) {
 	return localeFiles, nil
 }
 
-func (source *Source) getRemoteLocaleForLocaleFile(localeFile *LocaleFile) *phraseapp.Locale {
+// Removes all paths matching one of the exclude patterns of the source.
+func (source *Source) withoutExcludedFiles(paths []string) []string {
+	included := []string{}
+	for _, path := range paths {
+		pattern, excluded := source.excludedBy(path)
+		if excluded {
+			if Debug {
+				fmt.Printf("Excluding %s (matches %q)\n", path, pattern)
+			}
+			continue
+		}
+		included = append(included, path)
+	}
+	return included
+}
+
+func (source *Source) excludedBy(path string) (string, bool) {
+	for _, pattern := range source.Excludes {
+		if matchGlob(pattern, path) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+func (source *Source) getRemoteLocaleForLocaleFile(localeFile *LocaleFile) (*phraseapp.Locale, error) {
+	if ref, found := source.LocaleMapping.lookup(localeFile); found {
+		return findMappedLocale(source.RemoteLocales, ref)
+	}
+
 	candidates := source.RemoteLocales
 
 	filterApplied := false
@@ -447,17 +678,16 @@ func (source *Source) getRemoteLocaleForLocaleFile(localeFile *LocaleFile) *phra
 	// If no filter was applied the candidates list still contains all remote
 	// locales, while actually nothing matches.
 	if !filterApplied {
-		return nil
+		return nil, nil
 	}
 
 	switch len(candidates) {
 	case 0:
-		return nil
+		return nil, nil
 	case 1:
-		return candidates[0]
+		return candidates[0], nil
 	default:
-		// TODO I guess this should return an error, as this is a problem.
-		return candidates[0]
+		return nil, ambiguousLocaleError(fmt.Sprintf("file %q", localeFile.RelPath()), candidates)
 	}
 }
 
@@ -505,6 +735,17 @@ func splitPathToTokens(s string) []string {
 func extractParamsFromPathTokens(srcTokens, pathTokens []string) *LocaleFile {
 	localeFile := new(LocaleFile)
 
+	pattern, err := compileGlob(strings.Join(srcTokens, "/"))
+	if err == nil {
+		if params, ok := pattern.match(strings.Join(pathTokens, "/")); ok {
+			setPlaceholderValues(localeFile, params)
+			return localeFile
+		}
+	}
+
+	// Paths not matching the pattern completely are aligned segment by
+	// segment from both ends of the pattern.
+
 	for idx, token := range srcTokens {
 		pathToken := pathTokens[idx]
 		if token == "*" {
@@ -538,7 +779,7 @@ func extractParamsFromPathTokens(srcTokens, pathTokens []string) *LocaleFile {
 }
 
 func extractParamFromPathToken(localeFile *LocaleFile, srcToken, pathToken string) {
-	groups := placeholderRegexp.FindAllString(srcToken, -1)
+	groups := placeholderRegexp.FindAllStringSubmatch(srcToken, -1)
 	if len(groups) <= 0 {
 		return
 	}
@@ -549,8 +790,8 @@ func extractParamFromPathToken(localeFile *LocaleFile, srcToken, pathToken strin
 	}
 
 	for _, group := range groups {
-		replacer := fmt.Sprintf("(?P%s.+)", group)
-		match = strings.Replace(match, group, replacer, 1)
+		replacer := fmt.Sprintf("(?P<%s>.+)", placeholderGroupName(group[1], group[2]))
+		match = strings.Replace(match, group[0], replacer, 1)
 	}
 
 	if match == "" {
@@ -564,19 +805,13 @@ func extractParamFromPathToken(localeFile *LocaleFile, srcToken, pathToken strin
 
 	namedMatches := tmpRegexp.SubexpNames()
 	subMatches := tmpRegexp.FindStringSubmatch(pathToken)
+	values := map[string]string{}
 	for i, subMatch := range subMatches {
-		value := strings.Trim(subMatch, separator)
-		switch namedMatches[i] {
-		case "locale_code":
-			localeFile.Code = value
-		case "locale_name":
-			localeFile.Name = value
-		case "tag":
-			localeFile.Tag = value
-		default:
-			// ignore
+		if namedMatches[i] != "" {
+			values[namedMatches[i]] = strings.Trim(subMatch, separator)
 		}
 	}
+	setPlaceholderValues(localeFile, values)
 }
 
 func SourcesFromConfig(cmd *PushCommand) (Sources, error) {
@@ -585,7 +820,10 @@ func SourcesFromConfig(cmd *PushCommand) (Sources, error) {
 	}
 
 	tmp := struct {
-		Sources Sources
+		Sources        Sources
+		Concurrency    int
+		Excludes       []string
+		LocaleDefaults map[string]interface{} `yaml:"locale_defaults"`
 	}{}
 	err := yaml.Unmarshal(cmd.Config.Sources, &tmp)
 	if err != nil {
@@ -593,13 +831,40 @@ func SourcesFromConfig(cmd *PushCommand) (Sources, error) {
 	}
 	srcs := tmp.Sources
 
+	localeDefaults, err := parseLocaleDefaults("push.locale_defaults", tmp.LocaleDefaults)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Parallel == 0 {
+		cmd.Parallel = tmp.Concurrency
+	}
+
 	token := cmd.Credentials.Token
 	projectId := cmd.Config.DefaultProjectID
 	fileFormat := cmd.Config.DefaultFileFormat
 
+	names := []string{}
+	for _, source := range srcs {
+		if source != nil {
+			names = append(names, source.Name)
+		}
+	}
+	sel := &selection{only: cmd.Only, except: cmd.Except}
+	if err := sel.check("source", names); err != nil {
+		return nil, err
+	}
+
+	branch := ""
+	if cmd.Branch != "" {
+		if branch = normalizeBranch(cmd.Branch); branch == "" {
+			return nil, fmt.Errorf("invalid branch name %q", cmd.Branch)
+		}
+	}
+
 	validSources := []*Source{}
 	for _, source := range srcs {
-		if source == nil {
+		if source == nil || !sel.includes(source.Name) {
 			continue
 		}
 		if source.ProjectID == "" {
@@ -608,6 +873,10 @@ func SourcesFromConfig(cmd *PushCommand) (Sources, error) {
 		if source.AccessToken == "" {
 			source.AccessToken = token
 		}
+		source.LocaleMapping = cmd.clientConfig.LocaleMapping
+		source.Locales = cmd.Locales
+		source.LocaleDefaults = localeDefaults.merge(source.LocaleDefaults)
+		source.Excludes = append(append([]string{}, tmp.Excludes...), source.Excludes...)
 		if source.Params == nil {
 			source.Params = new(phraseapp.UploadParams)
 		}
@@ -620,6 +889,15 @@ func SourcesFromConfig(cmd *PushCommand) (Sources, error) {
 				source.Params.FileFormat = &fileFormat
 			}
 		}
+
+		if source.usesBranch() {
+			if branch == "" {
+				if branch, err = currentBranch(); err != nil {
+					return nil, err
+				}
+			}
+			source.setBranch(branch)
+		}
 		validSources = append(validSources, source)
 	}
 
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// Number of key IDs deleted per request.
+const cleanupBatchSize = 100
+
+// cleanupScope is the set of remote keys push --cleanup compares with the
+// pushed keys: all keys of the project, or the keys having all tags of an
+// upload.
+type cleanupScope struct {
+	projectID string
+	tags      []string
+}
+
+func (scope *cleanupScope) String() string {
+	if len(scope.tags) == 0 {
+		return fmt.Sprintf("project %s", scope.projectID)
+	}
+	return fmt.Sprintf("project %s, tags %s", scope.projectID, strings.Join(scope.tags, ","))
+}
+
+// cleaner deletes the keys of the cleanup scopes that were not part of the
+// pushed files. All uploads of a run are tagged with a unique tag, so keys
+// missing the tag after all uploads were processed are not used anymore.
+type cleaner struct {
+	client *phraseapp.Client
+	tag    string
+
+	// confirm is asked before keys are deleted. Keys are deleted without
+	// confirmation if it is nil.
+	confirm func(question string) bool
+
+	out io.Writer
+}
+
+func newCleanupTag() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("push-cleanup-%s-%s", time.Now().UTC().Format("20060102150405"), hex.EncodeToString(b))
+}
+
+// Returns the distinct scopes of the uploads. Project wide scopes are only
+// allowed if all sources are pushed, as keys of the other sources would be
+// deleted otherwise.
+func cleanupScopes(uploads []*upload, allSources bool) ([]*cleanupScope, error) {
+	scopes := []*cleanupScope{}
+	seen := map[string]bool{}
+	for _, u := range uploads {
+		scope := &cleanupScope{projectID: u.source.ProjectID}
+		if tags := u.source.uploadParams(u.localeFile).Tags; tags != nil {
+			for _, tag := range strings.Split(*tags, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" && !Contains(scope.tags, tag) {
+					scope.tags = append(scope.tags, tag)
+				}
+			}
+		}
+		sort.Strings(scope.tags)
+
+		if len(scope.tags) == 0 && !allSources {
+			return nil, fmt.Errorf("--cleanup of the whole project %s requires pushing all sources, tag the uploads of %s to limit the cleanup to them", scope.projectID, u.source.File)
+		}
+
+		if key := scope.String(); !seen[key] {
+			seen[key] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// Deletes the unused keys of the scopes if all uploads were processed
+// successfully. The cleanup tag is removed from the projects of the uploads
+// in any case, also if the cleanup is skipped or fails.
+func (c *cleaner) finish(scopes []*cleanupScope, uploads []*upload, processed bool) error {
+	err := fmt.Errorf("cleanup was skipped, as not all uploads were processed successfully")
+	if processed {
+		err = c.run(scopes)
+	}
+	if tagErr := c.removeTag(uploadedProjects(uploads)); err == nil {
+		err = tagErr
+	}
+	return err
+}
+
+func (c *cleaner) run(scopes []*cleanupScope) error {
+	projectIDs := []string{}
+	doomed := map[string][]*phraseapp.TranslationKey{}
+	for _, scope := range scopes {
+		keys, err := c.unusedKeys(scope)
+		if err != nil {
+			return err
+		}
+		if !Contains(projectIDs, scope.projectID) {
+			projectIDs = append(projectIDs, scope.projectID)
+		}
+		doomed[scope.projectID] = appendNewKeys(doomed[scope.projectID], keys)
+	}
+
+	for _, projectID := range projectIDs {
+		if err := c.deleteKeys(projectID, doomed[projectID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes the cleanup tag from the keys of the projects and deletes it, as
+// it is of no use after the cleanup.
+func (c *cleaner) removeTag(projectIDs []string) error {
+	q := "tags:" + c.tag
+	for _, projectID := range projectIDs {
+		err := rateLimit.do(func() error {
+			_, err := c.client.KeysUntag(projectID, &phraseapp.KeysUntagParams{Q: &q, Tags: &c.tag})
+			return err
+		})
+		if err == nil {
+			err = rateLimit.do(func() error {
+				return c.client.TagDelete(projectID, c.tag)
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove tag %s from project %s: %s", c.tag, projectID, err)
+		}
+	}
+	return nil
+}
+
+// Returns the projects the uploads were sent to, so the ones that may have
+// the cleanup tag.
+func uploadedProjects(uploads []*upload) []string {
+	projectIDs := []string{}
+	for _, u := range uploads {
+		if u.result != nil && !Contains(projectIDs, u.source.ProjectID) {
+			projectIDs = append(projectIDs, u.source.ProjectID)
+		}
+	}
+	return projectIDs
+}
+
+// Returns the keys of the scope that weren't tagged by the pushed uploads.
+func (c *cleaner) unusedKeys(scope *cleanupScope) ([]*phraseapp.TranslationKey, error) {
+	params := new(phraseapp.KeysListParams)
+	if len(scope.tags) > 0 {
+		q := "tags:" + scope.tags[0]
+		params.Q = &q
+	}
+
+	unused := []*phraseapp.TranslationKey{}
+	for page := 1; ; page++ {
+		var keys []*phraseapp.TranslationKey
+		err := rateLimit.do(func() (err error) {
+			keys, err = c.client.KeysList(scope.projectID, page, cleanupBatchSize, params)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if hasAllTags(key, scope.tags) && !Contains(key.Tags, c.tag) {
+				unused = append(unused, key)
+			}
+		}
+		if len(keys) < cleanupBatchSize {
+			return unused, nil
+		}
+	}
+}
+
+func (c *cleaner) deleteKeys(projectID string, keys []*phraseapp.TranslationKey) error {
+	if len(keys) == 0 {
+		fmt.Fprintf(c.out, "No unused keys in project %s\n", projectID)
+		return nil
+	}
+
+	fmt.Fprintf(c.out, "The following %d keys of project %s are not part of the pushed files:\n", len(keys), projectID)
+	for _, key := range keys {
+		fmt.Fprintf(c.out, "  %s\n", key.Name)
+	}
+
+	if c.confirm != nil && !c.confirm(fmt.Sprintf("Delete %d keys?", len(keys))) {
+		fmt.Fprintln(c.out, "Keys were not deleted")
+		return nil
+	}
+
+	deleted := int64(0)
+	for start := 0; start < len(keys); start += cleanupBatchSize {
+		end := start + cleanupBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		ids := []string{}
+		for _, key := range keys[start:end] {
+			ids = append(ids, key.ID)
+		}
+		q := "ids:" + strings.Join(ids, ",")
+
+		err := rateLimit.do(func() error {
+			res, err := c.client.KeysDelete(projectID, &phraseapp.KeysDeleteParams{Q: &q})
+			if err == nil {
+				deleted += res.RecordsAffected
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(c.out, "Deleted %d keys of project %s\n", deleted, projectID)
+	return nil
+}
+
+func allUploadsProcessed(uploads []*upload) bool {
+	for _, u := range uploads {
+		if u.createErr != nil || u.waitErr != nil || u.result == nil || u.result.State != uploadStateSuccess {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAllTags(key *phraseapp.TranslationKey, tags []string) bool {
+	for _, tag := range tags {
+		if !Contains(key.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func appendNewKeys(keys, more []*phraseapp.TranslationKey) []*phraseapp.TranslationKey {
+	for _, key := range more {
+		found := false
+		for _, k := range keys {
+			if k.ID == key.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Asks the question on stdout and reports whether it was answered with yes.
+func confirmOnStdin(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer := strings.ToLower(strings.TrimSpace(prompt()))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+// Prints what would be uploaded for the given locale files: the matched
+// remote locale, whether it would be created and the upload parameters.
+func (source *Source) printPlan(w io.Writer, localeFiles LocaleFiles) {
+	fmt.Fprintf(w, "Source %s (project: %s)\n", source.File, source.ProjectID)
+
+	for _, localeFile := range localeFiles {
+		fmt.Fprintf(w, "  %s\n", localeFile.RelPath())
+
+		switch {
+		case localeFile.ExistsRemote:
+			fmt.Fprintf(w, "    locale:  %s (id: %s, code: %s)\n", localeFile.Name, localeFile.ID, localeFile.Code)
+		case source.Format != nil && localeFile.shouldCreateLocale(source):
+			localeParams := source.localeParams(localeFile)
+			fmt.Fprintf(w, "    locale:  %s (code: %s) will be created", stringValue(localeParams.Name), stringValue(localeParams.Code))
+			if attributes := formatParams(localeParams, "name", "code"); attributes != "" {
+				fmt.Fprintf(w, " with %s", attributes)
+			}
+			fmt.Fprintln(w)
+		default:
+			fmt.Fprintln(w, "    locale:  no matching remote locale")
+		}
+
+		fmt.Fprintf(w, "    project: %s\n", source.ProjectID)
+		fmt.Fprintf(w, "    params:  %s\n", formatUploadParams(source.uploadParams(localeFile)))
+	}
+}
+
+// Renders the set upload parameters as sorted `key=value` pairs. The file
+// parameter is left out as the local path is printed separately.
+func formatUploadParams(params *phraseapp.UploadParams) string {
+	return formatParams(params, "file")
+}
+
+// Renders the set fields of API parameters as sorted `key=value` pairs,
+// leaving out the omitted keys.
+func formatParams(params interface{}, omit ...string) string {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err.Error()
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return err.Error()
+	}
+	for _, key := range omit {
+		delete(m, key)
+	}
+
+	pairs := []string{}
+	for k, v := range m {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, " ")
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
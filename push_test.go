@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
 )
@@ -32,7 +37,6 @@ func TestPushPreconditions(t *testing.T) {
 		"",
 		"no_extension",
 		"./<locale_code>/<locale_code>.yml",
-		"./*/*/en.yml",
 	} {
 		source.File = file
 		if err := source.CheckPreconditions(); err == nil {
@@ -43,7 +47,9 @@ func TestPushPreconditions(t *testing.T) {
 	for _, file := range []string{
 		"./<tag>/<locale_code>.yml",
 		"./*/en.yml",
+		"./*/*/en.yml",
 		"./*/<locale_name>/<locale_code>/<tag>.yml",
+		"./packages/*/locales/**/<locale_code>.json",
 	} {
 		source.File = file
 		if err := source.CheckPreconditions(); err != nil {
@@ -152,7 +158,10 @@ func TestGetRemoteLocaleForLocaleFile(t *testing.T) {
 		ID:   "",
 		Path: "",
 	}
-	locale := source.getRemoteLocaleForLocaleFile(localeFile)
+	locale, err := source.getRemoteLocaleForLocaleFile(localeFile)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
 	if locale.Name != localeFile.Name {
 		t.Errorf("Expected LocaleName to equal '%s' but was '%s'", "ennglish", localeFile.Name)
 		t.Fail()
@@ -465,8 +474,8 @@ func TestCheckPreconditions(t *testing.T) {
 		{"<locale_name>/<locale_name>.foo", ".foo", "<locale_name> can only occur once in a file pattern!"},
 		{"<locale_code>/<locale_code>.foo", ".foo", "<locale_code> can only occur once in a file pattern!"},
 		{"<tag>/<tag>.foo", ".foo", "<tag> can only occur once in a file pattern!"},
-		{"a/**/b/**/c.t", ".t", "** can only occur once in a file pattern!"},
-		{"a/*/b/**/d/*/c.t", ".t", "* can only occur once in a file pattern!"},
+		{"a/**/b/**/c.t", ".t", ""},
+		{"a/*/b/**/d/*/c.t", ".t", ""},
 		{"<locale_name>/<locale_code>/**/a/<tag>/*/c.t", ".t", ""},
 	}
 
@@ -572,9 +581,9 @@ func TestSystemFiles(t *testing.T) {
 		{"a/**/c/d.txt", []string{"a/b/c/d.txt", "a/y/c/d.txt"}},
 		{"a/**/c/*.txt", []string{"a/b/c/d.txt", "a/b/c/e.txt", "a/y/c/d.txt"}},
 		{"a/*/**/c/d.txt", []string{"a/b/c/d.txt", "a/y/c/d.txt"}},
+		// the file with the trailing blank doesn't end with `.en.yml`
 		{"./config/locales/**/*.en.yml", []string{
 			"config/locales/application.en.yml",
-			"config/locales/atrribtues/course.en.yml ",
 			"config/locales/devise.en.yml",
 			"config/locales/landing.en.yml",
 			"config/locales/layouts.en.yml",
@@ -751,7 +760,7 @@ func TestUploadFile(t *testing.T) {
 	file.Code = "locale-code"
 	file.Tag = "sometag"
 
-	err := src.uploadFile(c, file)
+	_, err := src.uploadFile(c, file)
 	if err != nil {
 		t.Errorf("didn't expect an error, got: %s", err)
 	}
@@ -807,8 +816,10 @@ func TestRemoteLocaleForLocaleFile(t *testing.T) {
 		lf := new(LocaleFile)
 		lf.Name = tti.name
 		lf.Code = tti.code
-		r := src.getRemoteLocaleForLocaleFile(lf)
+		r, err := src.getRemoteLocaleForLocaleFile(lf)
 		switch {
+		case err != nil:
+			t.Errorf("%d: didn't expect an error, got: %s", i, err)
 		case tti.expLocales == nil && r != nil:
 			t.Errorf("%d: didn't expect an locale, got %q", i, r.ID)
 		case tti.expLocales != nil && r == nil:
@@ -930,3 +941,620 @@ func TestShouldCreateLocale(t *testing.T) {
 		}
 	}
 }
+
+func TestPrintPlan(t *testing.T) {
+	d := setupFiles(t, "en.yml", "fr.yml")
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	src := getBaseSource()
+	src.File = "./<locale_code>.yml"
+	src.Format = &phraseapp.Format{ApiName: "yml"}
+	format := "yml"
+	src.Params.FileFormat = &format
+
+	localeFiles, err := src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	src.printPlan(buf, localeFiles)
+	out := buf.String()
+
+	for _, exp := range []string{
+		"Source ./<locale_code>.yml (project: project-id)",
+		"locale:  english (id: en-locale-id, code: en)",
+		"params:  file_format=yml locale_id=en-locale-id",
+		"locale:  fr (code: fr) will be created",
+		"params:  file_format=yml locale_id=fr",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("expected plan to contain %q, got:\n%s", exp, out)
+		}
+	}
+}
+
+func TestWaitForUpload(t *testing.T) {
+	defer func(interval time.Duration) { uploadPollInterval = interval }(uploadPollInterval)
+	uploadPollInterval = time.Millisecond
+
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		polls++
+		if req.URL.Path != "/v2/projects/project-id/uploads/upload-id" {
+			t.Errorf("unexpected request to %s", req.URL.Path)
+		}
+		state := "processing"
+		if polls == 3 {
+			state = "success"
+		}
+		fmt.Fprintf(resp, `{"id":"upload-id","state":%q,"summary":{"translation_keys_created":2,"translations_updated":1}}`, state)
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	result, err := waitForUpload(c, "project-id", &phraseapp.Upload{ID: "upload-id", State: "initialized"}, time.Second)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if polls != 3 {
+		t.Errorf("expected 3 polls, got %d", polls)
+	}
+	if result.State != "success" || result.Summary.TranslationKeysCreated != 2 {
+		t.Errorf("expected processed upload with summary, got %#v", result)
+	}
+
+	_, err = waitForUpload(c, "project-id", &phraseapp.Upload{ID: "upload-id", State: "processing"}, 0)
+	if err == nil {
+		t.Errorf("expected a timeout error")
+	}
+}
+
+func TestPrintUploadSummary(t *testing.T) {
+	uploads := []*upload{
+		{
+			localeFile: &LocaleFile{Path: "en.yml"},
+			result:     &phraseapp.Upload{State: "success", Summary: phraseapp.SummaryType{TranslationKeysCreated: 2, TranslationsCreated: 2}},
+		},
+		{
+			localeFile: &LocaleFile{Path: "de.yml"},
+			result:     &phraseapp.Upload{State: "success", Summary: phraseapp.SummaryType{TranslationsCreated: 3, TagsCreated: 1}},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := printUploadSummary(buf, uploads); err != nil {
+		t.Errorf("didn't expect an error, got: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header, 2 files and total, got:\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[3]); strings.Join(fields, " ") != "Total 2 5 0 1 0" {
+		t.Errorf("unexpected total line %q", lines[3])
+	}
+
+	uploads[1].result.State = "error"
+	if err := printUploadSummary(&bytes.Buffer{}, uploads); err == nil {
+		t.Errorf("expected an error for the failed upload")
+	}
+
+	uploads[1] = &upload{localeFile: &LocaleFile{Path: "fr.yml"}, createErr: fmt.Errorf("name is invalid")}
+	buf.Reset()
+	err := printUploadSummary(buf, uploads)
+	if err == nil || err.Error() != "1 of 2 uploads could not be processed" {
+		t.Errorf("expected an error for the locale that couldn't be created, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "fr.yml  failed to create locale (name is invalid)") {
+		t.Errorf("expected fr.yml to be listed as failed, got:\n%s", buf.String())
+	}
+}
+
+func TestLockfileSkipsUnchangedFiles(t *testing.T) {
+	d := setupFiles(t, "en.yml", "de.yml")
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	src := getBaseSource()
+	src.File = "./<locale_code>.yml"
+	localeFiles, err := src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	lock, err := loadLockfile(lockfileName)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if changed := lock.changed(nil, src, localeFiles); len(changed) != 2 {
+		t.Errorf("expected all files to be changed without a lockfile, got %d", len(changed))
+	}
+
+	for _, lf := range localeFiles {
+		if err := lock.record(src, lf, ""); err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+	}
+	if err := lock.save(); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	if err := ioutil.WriteFile("de.yml", []byte("de:\n  key: value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = loadLockfile(lockfileName)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	changed := lock.changed(nil, src, localeFiles)
+	if len(changed) != 1 || filepath.Base(changed[0].Path) != "de.yml" {
+		t.Errorf("expected only de.yml to be changed, got %v", changed)
+	}
+
+	tags := "new-tag"
+	src.Params.Tags = &tags
+	if changed := lock.changed(nil, src, localeFiles); len(changed) != 2 {
+		t.Errorf("expected changed params to mark all files as changed, got %d", len(changed))
+	}
+}
+
+func TestLockfileChecksPendingUploads(t *testing.T) {
+	d := setupFiles(t, "en.yml", "de.yml")
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		polls++
+		id := strings.TrimPrefix(req.URL.Path, "/v2/projects/project-id/uploads/")
+		state := map[string]string{"en-upload": "success", "de-upload": "error"}[id]
+		fmt.Fprintf(resp, `{"id":%q,"state":%q}`, id, state)
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	src := getBaseSource()
+	src.File = "./<locale_code>.yml"
+	localeFiles, err := src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	lock, err := loadLockfile(lockfileName)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	for _, lf := range localeFiles {
+		if err := lock.record(src, lf, lf.Code+"-upload"); err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+	}
+
+	changed := lock.changed(c, src, localeFiles)
+	if len(changed) != 1 || filepath.Base(changed[0].Path) != "de.yml" {
+		t.Errorf("expected de.yml with the failed upload to be changed, got %v", changed)
+	}
+
+	// the successful upload isn't checked again
+	polls = 0
+	lock.changed(c, src, localeFiles)
+	if polls != 1 {
+		t.Errorf("expected only the failed upload to be checked again, got %d checks", polls)
+	}
+}
+
+func TestRemoteLocaleForLocaleFileWithMapping(t *testing.T) {
+	rlBR := &phraseapp.Locale{ID: "br-locale-id", Name: "Portuguese (Brazil)", Code: "pt-BR"}
+	rlPT := &phraseapp.Locale{ID: "pt-locale-id", Name: "Portuguese", Code: "pt-BR"}
+
+	src := new(Source)
+	src.Params = new(phraseapp.UploadParams)
+	src.RemoteLocales = []*phraseapp.Locale{rlBR, rlPT}
+
+	_, err := src.getRemoteLocaleForLocaleFile(&LocaleFile{Code: "pt-BR"})
+	if err == nil {
+		t.Fatalf("expected an error for an ambiguous locale")
+	}
+	for _, id := range []string{"br-locale-id", "pt-locale-id"} {
+		if !strings.Contains(err.Error(), id) {
+			t.Errorf("expected error to list candidate %q, got: %s", id, err)
+		}
+	}
+
+	src.LocaleMapping = LocaleMapping{"pt_BR": "Portuguese (Brazil)", "pt": "pt-locale-id", "xx": "missing"}
+	for code, expID := range map[string]string{"pt_BR": "br-locale-id", "pt": "pt-locale-id"} {
+		r, err := src.getRemoteLocaleForLocaleFile(&LocaleFile{Code: code})
+		switch {
+		case err != nil:
+			t.Errorf("%s: didn't expect an error, got: %s", code, err)
+		case r == nil || r.ID != expID:
+			t.Errorf("%s: expected locale %q, got %v", code, expID, r)
+		}
+	}
+
+	if _, err := src.getRemoteLocaleForLocaleFile(&LocaleFile{Code: "xx"}); err == nil {
+		t.Errorf("expected an error for a mapping to a missing locale")
+	}
+}
+
+func TestSourcesFromConfigExcludes(t *testing.T) {
+	cmd := &PushCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Sources = []byte(`
+excludes:
+- "**/vendor/**"
+sources:
+- file: ./config/locales/**/*.en.yml
+  excludes:
+  - "*.fixture.en.yml"
+`)
+
+	sources, err := SourcesFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	exp := []string{"**/vendor/**", "*.fixture.en.yml"}
+	if strings.Join(sources[0].Excludes, " ") != strings.Join(exp, " ") {
+		t.Errorf("expected excludes %v, got %v", exp, sources[0].Excludes)
+	}
+}
+
+func TestLocaleFilesExcludes(t *testing.T) {
+	d := setupFiles(t,
+		"config/locales/application.en.yml",
+		"config/locales/users.fixture.en.yml",
+		"config/locales/vendor/devise.en.yml",
+		"config/locales/admin/vendor/gem.en.yml",
+		"config/locales/admin/admin.en.yml",
+	)
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	src := new(Source)
+	src.File = "./config/locales/**/*.en.yml"
+	src.Excludes = []string{"config/**/vendor/**", "*.fixture.en.yml"}
+
+	files, err := src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	got := []string{}
+	for _, lf := range files {
+		got = append(got, lf.RelPath())
+	}
+	sort.Strings(got)
+
+	exp := []string{"config/locales/admin/admin.en.yml", "config/locales/application.en.yml"}
+	if strings.Join(got, " ") != strings.Join(exp, " ") {
+		t.Errorf("expected files %v, got %v", exp, got)
+	}
+}
+
+func TestSourcesFromConfigSelection(t *testing.T) {
+	config := []byte(`
+sources:
+- name: web
+  file: ./web/<locale_code>.yml
+- name: mobile
+  file: ./mobile/<locale_code>.yml
+- file: ./shared/<locale_code>.yml
+`)
+
+	tt := []struct {
+		only, except []string
+		files        []string
+		err          string
+	}{
+		{nil, nil, []string{"./web/<locale_code>.yml", "./mobile/<locale_code>.yml", "./shared/<locale_code>.yml"}, ""},
+		{[]string{"web"}, nil, []string{"./web/<locale_code>.yml"}, ""},
+		{nil, []string{"web"}, []string{"./mobile/<locale_code>.yml", "./shared/<locale_code>.yml"}, ""},
+		{[]string{"web", "mobile"}, []string{"mobile"}, []string{"./web/<locale_code>.yml"}, ""},
+		{[]string{"desktop"}, nil, nil, `there is no source named "desktop"`},
+	}
+
+	for _, tti := range tt {
+		cmd := &PushCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+		cmd.Config.Sources = config
+		cmd.Only, cmd.Except = tti.only, tti.except
+
+		sources, err := SourcesFromConfig(cmd)
+		if tti.err != "" {
+			if err == nil || err.Error() != tti.err {
+				t.Errorf("only=%v except=%v: expected error %q, got: %v", tti.only, tti.except, tti.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("only=%v except=%v: didn't expect an error, got: %s", tti.only, tti.except, err)
+			continue
+		}
+
+		files := []string{}
+		for _, source := range sources {
+			files = append(files, source.File)
+		}
+		if strings.Join(files, " ") != strings.Join(tti.files, " ") {
+			t.Errorf("only=%v except=%v: expected sources %v, got %v", tti.only, tti.except, tti.files, files)
+		}
+	}
+}
+
+func TestPushWaitTimeoutMustBePositive(t *testing.T) {
+	for _, cmd := range []*PushCommand{
+		{Wait: true, WaitTimeout: 0},
+		{Cleanup: true, WaitTimeout: -1},
+	} {
+		cmd.Config = &phraseapp.Config{Credentials: new(phraseapp.Credentials)}
+		err := cmd.Run()
+		if err == nil || !strings.Contains(err.Error(), "--wait-timeout must be a positive number") {
+			t.Errorf("wait-timeout=%d: expected an error, got: %v", cmd.WaitTimeout, err)
+		}
+	}
+}
+
+func TestSourcesFromConfigDuplicateNames(t *testing.T) {
+	cmd := &PushCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Sources = []byte(`
+sources:
+- name: web
+  file: ./web/<locale_code>.yml
+- name: web
+  file: ./mobile/<locale_code>.yml
+`)
+
+	_, err := SourcesFromConfig(cmd)
+	if err == nil || err.Error() != `the source name "web" is used more than once` {
+		t.Errorf("expected duplicate name error, got: %v", err)
+	}
+}
+
+func TestLocaleFilesLocaleFilter(t *testing.T) {
+	d := setupFiles(t, "locales/en.yml", "locales/de.yml", "locales/fr.yml")
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	src := new(Source)
+	src.File = "./locales/<locale_code>.yml"
+	src.RemoteLocales = getBaseLocales()
+	src.Locales = []string{"de", "fr"}
+
+	files, err := src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	got := []string{}
+	for _, lf := range files {
+		got = append(got, lf.Code)
+	}
+	sort.Strings(got)
+
+	if strings.Join(got, " ") != "de fr" {
+		t.Errorf("expected locale files for de and fr, got %v", got)
+	}
+
+	src.Locales = []string{"it"}
+	files, err = src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no locale files, got %d", len(files))
+	}
+}
+
+func TestGitChangesFilter(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	d := setupFiles(t, "locales/en.yml", "locales/de.yml", "locales/fr.yml")
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	if _, err := changedSince("HEAD"); err == nil {
+		t.Errorf("expected an error outside of a git work tree")
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "locales/en.yml", "locales/de.yml"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if _, err := git(args...); err != nil {
+			t.Fatalf("git %v failed: %s", args, err)
+		}
+	}
+	if err := ioutil.WriteFile("locales/de.yml", []byte("de:\n  changed: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := new(Source)
+	src.File = "./locales/<locale_code>.yml"
+	src.RemoteLocales = getBaseLocales()
+	localeFiles, err := src.LocaleFiles()
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	changes, err := changedSince("HEAD")
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	got := []string{}
+	for _, lf := range changes.filter(localeFiles) {
+		got = append(got, lf.RelPath())
+	}
+	sort.Strings(got)
+
+	exp := []string{"locales/de.yml", "locales/fr.yml"}
+	if strings.Join(got, " ") != strings.Join(exp, " ") {
+		t.Errorf("expected changed files %v, got %v", exp, got)
+	}
+
+	if _, err := changedSince("no-such-revision"); err == nil {
+		t.Errorf("expected an error for an unknown revision")
+	}
+}
+
+func TestLocaleParamsWithDefaults(t *testing.T) {
+	cmd := &PushCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Sources = []byte(`
+locale_defaults:
+  source_locale_id: en-locale-id
+  main: false
+  fa:
+    rtl: false
+sources:
+- file: ./locales/<locale_code>.yml
+  locale_defaults:
+    main: true
+    pt-BR:
+      name: Brazilian Portuguese
+      default: false
+`)
+
+	sources, err := SourcesFromConfig(cmd)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	source := sources[0]
+
+	tt := []struct {
+		code   string
+		params string
+	}{
+		{"de", "code=de main=true name=de source_locale_id=en-locale-id"},
+		{"ar-EG", "code=ar-EG main=true name=ar-EG rtl=true source_locale_id=en-locale-id"},
+		{"fa", "code=fa main=true name=fa rtl=false source_locale_id=en-locale-id"},
+		{"pt-BR", "code=pt-BR default=false main=true name=Brazilian Portuguese source_locale_id=en-locale-id"},
+	}
+
+	for _, tti := range tt {
+		params := source.localeParams(&LocaleFile{Code: tti.code})
+		if got := formatParams(params); got != tti.params {
+			t.Errorf("%s: expected params %q, got %q", tti.code, tti.params, got)
+		}
+	}
+
+	cmd.Config.Sources = []byte(`
+sources:
+- file: ./locales/<locale_code>.yml
+  locale_defaults:
+    ar:
+      rtl: "yes"
+`)
+	if _, err := SourcesFromConfig(cmd); err == nil {
+		t.Errorf("expected an error for an invalid rtl value")
+	}
+}
+
+func TestCleanupScopes(t *testing.T) {
+	tags := "app"
+	tagged := &Source{ProjectID: "project-id", Params: &phraseapp.UploadParams{Tags: &tags}}
+	untagged := &Source{ProjectID: "project-id", Params: new(phraseapp.UploadParams)}
+
+	uploads := []*upload{
+		{source: tagged, localeFile: &LocaleFile{Code: "en", Tag: "web"}},
+		{source: tagged, localeFile: &LocaleFile{Code: "de", Tag: "web"}},
+		{source: untagged, localeFile: &LocaleFile{Code: "en"}},
+	}
+
+	scopes, err := cleanupScopes(uploads, true)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	got := []string{}
+	for _, scope := range scopes {
+		got = append(got, scope.String())
+	}
+	exp := []string{"project project-id, tags app,web", "project project-id"}
+	if strings.Join(got, "|") != strings.Join(exp, "|") {
+		t.Errorf("expected scopes %v, got %v", exp, got)
+	}
+
+	if _, err := cleanupScopes(uploads, false); err == nil {
+		t.Errorf("expected an error for a project wide cleanup of some sources")
+	}
+}
+
+func TestCleanerRun(t *testing.T) {
+	requests := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", req.Method, req.URL.Path, strings.TrimSpace(string(body))))
+
+		switch {
+		case req.Method == "GET":
+			fmt.Fprint(resp, `[
+				{"id":"1","name":"used","tags":["app","push-cleanup-1"]},
+				{"id":"2","name":"unused","tags":["app"]},
+				{"id":"3","name":"other","tags":["other"]}
+			]`)
+		case strings.Contains(req.URL.Path, "/tags/"):
+			resp.WriteHeader(http.StatusNoContent)
+		default:
+			fmt.Fprint(resp, `{"records_affected":1}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := new(phraseapp.Client)
+	c.Credentials = &phraseapp.Credentials{Host: srv.URL, Token: "some_token"}
+
+	out := new(bytes.Buffer)
+	questions := []string{}
+	cl := &cleaner{client: c, tag: "push-cleanup-1", out: out, confirm: func(question string) bool {
+		questions = append(questions, question)
+		return true
+	}}
+
+	scopes := []*cleanupScope{{projectID: "project-id", tags: []string{"app"}}}
+	uploads := []*upload{
+		{source: &Source{ProjectID: "project-id"}, result: new(phraseapp.Upload)},
+		{source: &Source{ProjectID: "other-project-id"}},
+	}
+	err := cl.finish(scopes, uploads, true)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	removeTag := []string{
+		`PATCH /v2/projects/project-id/keys/untag {"q":"tags:push-cleanup-1","tags":"push-cleanup-1"}`,
+		`DELETE /v2/projects/project-id/tags/push-cleanup-1 `,
+	}
+	exp := append([]string{
+		`GET /v2/projects/project-id/keys {"q":"tags:app"}`,
+		`DELETE /v2/projects/project-id/keys {"q":"ids:2"}`,
+	}, removeTag...)
+	if strings.Join(requests, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("expected requests\n%s\ngot\n%s", strings.Join(exp, "\n"), strings.Join(requests, "\n"))
+	}
+
+	if len(questions) != 1 || questions[0] != "Delete 1 keys?" {
+		t.Errorf("expected confirmation to be asked once, got %v", questions)
+	}
+	if !strings.Contains(out.String(), "  unused\n") || strings.Contains(out.String(), "  used\n") {
+		t.Errorf("expected only the unused key to be listed, got:\n%s", out.String())
+	}
+
+	// a skipped cleanup fails, but still removes the tag
+	requests = []string{}
+	err = cl.finish(scopes, uploads, false)
+	if err == nil || !strings.Contains(err.Error(), "cleanup was skipped") {
+		t.Errorf("expected an error for the skipped cleanup, got: %v", err)
+	}
+	if strings.Join(requests, "\n") != strings.Join(removeTag, "\n") {
+		t.Errorf("expected requests\n%s\ngot\n%s", strings.Join(removeTag, "\n"), strings.Join(requests, "\n"))
+	}
+}
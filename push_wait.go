@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+var uploadPollInterval = 2 * time.Second
+
+const (
+	uploadStateSuccess = "success"
+	uploadStateError   = "error"
+)
+
+func isFinalUploadState(state string) bool {
+	return state == uploadStateSuccess || state == uploadStateError
+}
+
+// Polls the upload until PhraseApp finished processing it or the timeout is
+// exceeded. The last known state of the upload is returned in both cases.
+func waitForUpload(client *phraseapp.Client, projectID string, result *phraseapp.Upload, timeout time.Duration) (*phraseapp.Upload, error) {
+	deadline := time.Now().Add(timeout)
+
+	for !isFinalUploadState(result.State) {
+		if time.Now().After(deadline) {
+			return result, fmt.Errorf("upload %s was not processed within %s (state: %s)", result.ID, timeout, result.State)
+		}
+
+		time.Sleep(uploadPollInterval)
+
+		var current *phraseapp.Upload
+		err := rateLimit.do(func() (err error) {
+			current, err = client.UploadShow(projectID, result.ID)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+		result = current
+	}
+
+	return result, nil
+}
+
+// Prints the server side import summary of every upload and the totals. An
+// error is returned if any of the uploads could not be processed.
+func printUploadSummary(w io.Writer, uploads []*upload) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "File\tState\tKeys created\tTranslations created\tTranslations updated\tTags created\tLocales created")
+
+	total := phraseapp.SummaryType{}
+	failed := 0
+	for _, u := range uploads {
+		// the file wasn't uploaded, as its locale couldn't be created
+		if u.createErr != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tfailed to create locale (%s)\t%s\n", u.localeFile.RelPath(), u.createErr, formatSummary(phraseapp.SummaryType{}))
+			continue
+		}
+		if u.result == nil {
+			continue
+		}
+
+		state := u.result.State
+		switch {
+		case u.waitErr != nil:
+			failed++
+			state = fmt.Sprintf("%s (%s)", state, u.waitErr)
+		case state == uploadStateError:
+			failed++
+		}
+
+		summary := u.result.Summary
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", u.localeFile.RelPath(), state, formatSummary(summary))
+
+		total.TranslationKeysCreated += summary.TranslationKeysCreated
+		total.TranslationsCreated += summary.TranslationsCreated
+		total.TranslationsUpdated += summary.TranslationsUpdated
+		total.TagsCreated += summary.TagsCreated
+		total.LocalesCreated += summary.LocalesCreated
+	}
+	fmt.Fprintf(tw, "Total\t\t%s\n", formatSummary(total))
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d uploads could not be processed", failed, len(uploads))
+	}
+	return nil
+}
+
+func formatSummary(summary phraseapp.SummaryType) string {
+	return fmt.Sprintf("%d\t%d\t%d\t%d\t%d",
+		summary.TranslationKeysCreated,
+		summary.TranslationsCreated,
+		summary.TranslationsUpdated,
+		summary.TagsCreated,
+		summary.LocalesCreated,
+	)
+}
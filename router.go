@@ -14,7 +14,7 @@ const (
 	RevisionGenerator = "94d1286639d8e406fe02da37474b644d622d5498"
 )
 
-func router(cfg *phraseapp.Config) (*cli.Router, error) {
+func router(cfg *phraseapp.Config, clientCfg *clientConfig) (*cli.Router, error) {
 	r := cli.NewRouter()
 
 	if cmd, err := newAuthorizationCreate(cfg); err != nil {
@@ -309,9 +309,13 @@ func router(cfg *phraseapp.Config) (*cli.Router, error) {
 
 	r.Register("webhooks/list", newWebhooksList(cfg), "List all webhooks for the given project.")
 
-	r.Register("pull", &PullCommand{Config: cfg}, "Download locales from your PhraseApp project.\n  You can provide parameters supported by the locales#download endpoint http://docs.phraseapp.com/api/v2/locales/#download\n  in your configuration (.phraseapp.yml) for each source.\n  See our configuration guide for more information http://docs.phraseapp.com/developers/cli/configuration/")
+	r.Register("pull", &PullCommand{Config: cfg, clientConfig: *clientCfg}, "Download locales from your PhraseApp project.\n  You can provide parameters supported by the locales#download endpoint http://docs.phraseapp.com/api/v2/locales/#download\n  in your configuration (.phraseapp.yml) for each source.\n  See our configuration guide for more information http://docs.phraseapp.com/developers/cli/configuration/")
 
-	r.Register("push", &PushCommand{Config: cfg}, "Upload locales to your PhraseApp project.\n  You can provide parameters supported by the uploads#create endpoint http://docs.phraseapp.com/api/v2/uploads/#create\n  in your configuration (.phraseapp.yml) for each source.\n  See our configuration guide for more information http://docs.phraseapp.com/developers/cli/configuration/")
+	r.Register("push", &PushCommand{Config: cfg, clientConfig: *clientCfg}, "Upload locales to your PhraseApp project.\n  You can provide parameters supported by the uploads#create endpoint http://docs.phraseapp.com/api/v2/uploads/#create\n  in your configuration (.phraseapp.yml) for each source.\n  See our configuration guide for more information http://docs.phraseapp.com/developers/cli/configuration/")
+
+	r.Register("validate", &ValidateCommand{Config: cfg}, "Check the syntax of the files of all push sources locally.\n  Files in yml, simple_json, nested_json, gettext, xml and properties format are checked.")
+
+	r.Register("branch/cleanup", &BranchCleanupCommand{Config: cfg}, "Remove the tag of a merged git branch from all keys of the projects of your push sources.")
 
 	r.Register("init", &WizardCommand{}, "Configure your PhraseApp client.")
 
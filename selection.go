@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+)
+
+// selection limits push and pull to the sources or targets with the given
+// names. Empty lists select everything.
+type selection struct {
+	only   []string
+	except []string
+}
+
+// Checks that all names refer to a configured source or target and that no
+// name is used twice.
+func (sel *selection) check(kind string, names []string) error {
+	known := map[string]bool{}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if known[name] {
+			return fmt.Errorf("the %s name %q is used more than once", kind, name)
+		}
+		known[name] = true
+	}
+
+	for _, name := range append(append([]string{}, sel.only...), sel.except...) {
+		if !known[name] {
+			return fmt.Errorf("there is no %s named %q", kind, name)
+		}
+	}
+	return nil
+}
+
+func (sel *selection) includes(name string) bool {
+	if len(sel.only) > 0 && !Contains(sel.only, name) {
+		return false
+	}
+	return !Contains(sel.except, name)
+}
+
+// Reports whether one of the given codes (or names or IDs) of a locale file
+// is in the filter. An empty filter selects all locales.
+func selectsLocale(filter []string, codes ...string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if code != "" && Contains(filter, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a list of strings from the raw YAML value of a config key.
+func parseStringList(key string, raw []byte) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list := []string{}
+	if err := yaml.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("%s must be a list of strings", key)
+	}
+	return list, nil
+}
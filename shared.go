@@ -6,7 +6,6 @@ import (
 	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
@@ -18,8 +17,6 @@ type LocaleFile struct {
 	ExistsRemote                          bool
 }
 
-var placeholderRegexp = regexp.MustCompile("<(locale_name|tag|locale_code)>")
-
 func ValidPath(file, formatName, formatExtension string) error {
 	if strings.TrimSpace(file) == "" {
 		return fmt.Errorf(
@@ -29,7 +26,7 @@ func ValidPath(file, formatName, formatExtension string) error {
 
 	fileExtension := strings.Trim(filepath.Ext(file), ".")
 
-	if fileExtension == "<locale_code>" {
+	if fileExtension != "" && placeholderRegexp.FindString(fileExtension) == fileExtension {
 		return nil
 	}
 
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+)
+
+var snapshotsDir = filepath.Join(stateDir, "snapshots")
+
+const (
+	// Name of the file describing the files of a snapshot.
+	snapshotManifestName = "snapshot.yml"
+
+	// Number of snapshots kept if the pull config doesn't set snapshots.
+	defaultSnapshots = 10
+
+	snapshotTimeFormat = "20060102-150405"
+)
+
+// snapshot keeps the previous contents of the files changed by a pull, so
+// that they can be restored with pull --restore. Each snapshot is a
+// directory named after the time of the pull, holding a copy of every file
+// that existed and a manifest. The directory is only created once a file
+// changes.
+type snapshot struct {
+	root      string
+	createdAt time.Time
+
+	mutex    sync.Mutex
+	name     string
+	manifest *snapshotManifest
+}
+
+type snapshotManifest struct {
+	CreatedAt string          `yaml:"created_at"`
+	Files     []*snapshotFile `yaml:"files"`
+}
+
+type snapshotFile struct {
+	Path string `yaml:"path"`
+
+	// Copy is the name of the copy in the files directory of the snapshot.
+	// It is empty if the file didn't exist before the pull.
+	Copy string      `yaml:"copy,omitempty"`
+	Mode os.FileMode `yaml:"mode,omitempty"`
+}
+
+func newSnapshot(root string, createdAt time.Time) *snapshot {
+	return &snapshot{
+		root:      root,
+		createdAt: createdAt.UTC(),
+		manifest:  &snapshotManifest{CreatedAt: createdAt.UTC().Format(time.RFC3339), Files: []*snapshotFile{}},
+	}
+}
+
+// Records the current content of the file at path before it is replaced
+// with content. Files without changes aren't recorded, files recorded before
+// keep their first content.
+func (s *snapshot) add(path string, content []byte) error {
+	previous, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		previous = nil
+	case err != nil:
+		return err
+	case bytes.Equal(previous, content):
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, file := range s.manifest.Files {
+		if file.Path == path {
+			return nil
+		}
+	}
+	if err := s.create(); err != nil {
+		return err
+	}
+
+	file := &snapshotFile{Path: path}
+	if previous != nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		file.Copy = fmt.Sprintf("%d-%s", len(s.manifest.Files), filepath.Base(path))
+		file.Mode = info.Mode().Perm()
+		if err := ioutil.WriteFile(filepath.Join(s.root, s.name, "files", file.Copy), previous, defaultFileMode); err != nil {
+			return err
+		}
+	}
+	s.manifest.Files = append(s.manifest.Files, file)
+	return nil
+}
+
+// Creates the directory of the snapshot unless it exists already.
+func (s *snapshot) create() error {
+	if s.name != "" {
+		return nil
+	}
+
+	name := s.createdAt.Format(snapshotTimeFormat)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(s.root, name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", s.createdAt.Format(snapshotTimeFormat), i)
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.root, name, "files"), defaultDirMode); err != nil {
+		return err
+	}
+	s.name = name
+	return nil
+}
+
+// Writes the manifest of the snapshot if any file was recorded and removes
+// the oldest snapshots, so that at most keep snapshots are left.
+func (s *snapshot) save(keep int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.name == "" {
+		return nil
+	}
+
+	content, err := yaml.Marshal(s.manifest)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.root, s.name, snapshotManifestName), content, defaultFileMode); err != nil {
+		return err
+	}
+
+	names, err := listSnapshots(s.root)
+	if err != nil {
+		return err
+	}
+	for len(names) > keep {
+		if err := os.RemoveAll(filepath.Join(s.root, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// Returns the names of the snapshots in root, oldest first.
+func listSnapshots(root string) ([]string, error) {
+	infos, err := ioutil.ReadDir(root)
+	switch {
+	case os.IsNotExist(err):
+		return []string{}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	names := []string{}
+	for _, info := range infos {
+		if _, err := os.Stat(filepath.Join(root, info.Name(), snapshotManifestName)); info.IsDir() && err == nil {
+			names = append(names, info.Name())
+		}
+	}
+	return names, nil
+}
+
+func loadSnapshotManifest(root, name string) (*snapshotManifest, error) {
+	path := filepath.Join(root, name, snapshotManifestName)
+	content, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil, fmt.Errorf("there is no snapshot %q", name)
+	case err != nil:
+		return nil, err
+	}
+
+	manifest := new(snapshotManifest)
+	if err := yaml.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("%s is invalid: %s", path, err)
+	}
+	return manifest, nil
+}
+
+// Prints the snapshots in root, newest first.
+func printSnapshots(root string) error {
+	names, err := listSnapshots(root)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("There are no snapshots")
+		return nil
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		manifest, err := loadSnapshotManifest(root, names[i])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s  %d files\n", names[i], len(manifest.Files))
+	}
+	return nil
+}
+
+// Puts the files of the snapshot back as they were before the pull that
+// created it. Files created by that pull are removed. Without a name the
+// newest snapshot is restored.
+func restoreSnapshot(root, name string) error {
+	if name == "" {
+		names, err := listSnapshots(root)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("there are no snapshots to restore")
+		}
+		name = names[len(names)-1]
+	}
+
+	manifest, err := loadSnapshotManifest(root, name)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range manifest.Files {
+		if file.Copy == "" {
+			err := os.Remove(file.Path)
+			switch {
+			case os.IsNotExist(err):
+			case err != nil:
+				return err
+			default:
+				fmt.Println("Removed", file.Path)
+			}
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(root, name, "files", file.Copy))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(file.Path), defaultDirMode); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(file.Path, content, file.Mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(file.Path, file.Mode); err != nil {
+			return err
+		}
+		fmt.Println("Restored", file.Path)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	dir := setupFiles(t, "locales/en.yml", "locales/de.yml")
+	defer os.RemoveAll(dir)
+	defer pushd(t, dir)()
+
+	if err := ioutil.WriteFile("locales/en.yml", []byte("en: old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod("locales/en.yml", 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulates a pull changing en.yml, creating fr.yml and leaving de.yml
+	s := newSnapshot(snapshotsDir, time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC))
+	for path, content := range map[string]string{"locales/en.yml": "en: new\n", "locales/fr.yml": "fr: new\n", "locales/de.yml": ""} {
+		if err := s.add(path, []byte(content)); err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+		if err := writeFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod("locales/en.yml", 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(2); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	if s.name != "20160501-120000" || len(s.manifest.Files) != 2 {
+		t.Errorf("expected a snapshot of 2 files named 20160501-120000, got %d files in %q", len(s.manifest.Files), s.name)
+	}
+
+	if err := restoreSnapshot(snapshotsDir, ""); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	content, err := ioutil.ReadFile("locales/en.yml")
+	if err != nil || string(content) != "en: old\n" {
+		t.Errorf("expected en.yml to be restored, got %q (%v)", content, err)
+	}
+	if info, err := os.Stat("locales/en.yml"); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected en.yml to get back mode 0600, got %v (%v)", info, err)
+	}
+	if _, err := os.Stat("locales/fr.yml"); !os.IsNotExist(err) {
+		t.Errorf("expected fr.yml to be removed, got %v", err)
+	}
+
+	if err := restoreSnapshot(snapshotsDir, "20160501"); err == nil || err.Error() != `there is no snapshot "20160501"` {
+		t.Errorf("expected an error about the unknown snapshot, got: %v", err)
+	}
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	dir := setupFiles(t, "en.yml")
+	defer os.RemoveAll(dir)
+	defer pushd(t, dir)()
+
+	start := time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, content := range []string{"a", "b", "c", "d"} {
+		// snapshots of the same second get a suffix
+		s := newSnapshot(snapshotsDir, start.Add(time.Duration(i/2)*time.Second))
+		if err := s.add("en.yml", []byte(content)); err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+		if err := s.save(3); err != nil {
+			t.Fatalf("didn't expect an error, got: %s", err)
+		}
+	}
+
+	// a pull without changes doesn't create a snapshot
+	if err := newSnapshot(snapshotsDir, start.Add(time.Hour)).save(3); err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+
+	names, err := listSnapshots(snapshotsDir)
+	if err != nil {
+		t.Fatalf("didn't expect an error, got: %s", err)
+	}
+	expected := []string{"20160501-120000-1", "20160501-120001", "20160501-120001-1"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected snapshots %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/gopkg.in/yaml.v2"
+)
+
+// syntaxError describes a syntax error in a locale file. Line and Column
+// start at 1 and are 0 if unknown.
+type syntaxError struct {
+	Path         string
+	Line, Column int
+	Message      string
+}
+
+func (e *syntaxError) Error() string {
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+	default:
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+}
+
+type syntaxChecker func(content []byte) *syntaxError
+
+// Local syntax checks by file format. Files of other formats are only checked
+// by the API.
+var syntaxCheckers = map[string]syntaxChecker{
+	"yml":          checkYAML,
+	"yml_symfony":  checkYAML,
+	"yml_symfony2": checkYAML,
+	"simple_json":  checkJSONObject,
+	"nested_json":  checkJSONObject,
+	"gettext":      checkGettext,
+	"xml":          checkXML,
+	"properties":   checkProperties,
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Checks the syntax of the file if there is a local check for its format.
+func checkFileSyntax(path, format string, content []byte) *syntaxError {
+	check, found := syntaxCheckers[format]
+	if !found {
+		return nil
+	}
+
+	if err := check(bytes.TrimPrefix(content, utf8BOM)); err != nil {
+		err.Path = path
+		return err
+	}
+	return nil
+}
+
+var yamlLineRegexp = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func checkYAML(content []byte) *syntaxError {
+	var v interface{}
+	err := yaml.Unmarshal(content, &v)
+	if err == nil {
+		return nil
+	}
+
+	// the yaml package only reports the line of an error
+	if m := yamlLineRegexp.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &syntaxError{Line: line, Message: m[2]}
+	}
+	return &syntaxError{Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+}
+
+func checkJSONObject(content []byte) *syntaxError {
+	var v interface{}
+	err := json.Unmarshal(content, &v)
+	if serr, ok := err.(*json.SyntaxError); ok {
+		line, column := position(content, int(serr.Offset)-1)
+		return &syntaxError{Line: line, Column: column, Message: serr.Error()}
+	}
+	if err != nil {
+		return &syntaxError{Message: err.Error()}
+	}
+
+	if _, ok := v.(map[string]interface{}); !ok {
+		start := len(content) - len(bytes.TrimLeft(content, " \t\r\n"))
+		line, column := position(content, start)
+		return &syntaxError{Line: line, Column: column, Message: "expected a JSON object"}
+	}
+	return nil
+}
+
+func checkXML(content []byte) *syntaxError {
+	d := xml.NewDecoder(bytes.NewReader(content))
+	root := false
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			message := err.Error()
+			if serr, ok := err.(*xml.SyntaxError); ok {
+				message = serr.Msg
+			}
+			line, column := position(content, int(d.InputOffset()))
+			return &syntaxError{Line: line, Column: column, Message: message}
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			root = true
+		}
+	}
+
+	if !root {
+		return &syntaxError{Line: 1, Column: 1, Message: "missing root element"}
+	}
+	return nil
+}
+
+var msgstrIndexRegexp = regexp.MustCompile(`^msgstr\[\d+\]$`)
+
+func checkGettext(content []byte) *syntaxError {
+	keyword, hasMsgid := "", false
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+
+		errorAt := func(offset int, format string, args ...interface{}) *syntaxError {
+			column := utf8.RuneCountInString(line[:indent+offset]) + 1
+			return &syntaxError{Line: i + 1, Column: column, Message: fmt.Sprintf(format, args...)}
+		}
+
+		switch {
+		case strings.TrimSpace(trimmed) == "":
+			keyword = ""
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, `"`):
+			if keyword == "" {
+				return errorAt(0, "string continuation without keyword")
+			}
+			if offset, msg := checkQuotedString(trimmed); msg != "" {
+				return errorAt(offset, "%s", msg)
+			}
+			continue
+		}
+
+		keyword = trimmed
+		if end := strings.IndexAny(trimmed, " \t"); end >= 0 {
+			keyword = trimmed[:end]
+		}
+
+		switch {
+		case keyword == "msgctxt":
+			hasMsgid = false
+		case keyword == "msgid":
+			hasMsgid = true
+		case keyword == "msgid_plural", keyword == "msgstr", msgstrIndexRegexp.MatchString(keyword):
+			if !hasMsgid {
+				return errorAt(0, "%s without msgid", keyword)
+			}
+		default:
+			return errorAt(0, "unknown keyword %q", keyword)
+		}
+
+		rest := strings.TrimLeft(trimmed[len(keyword):], " \t")
+		restOffset := len(trimmed) - len(rest)
+		if offset, msg := checkQuotedString(rest); msg != "" {
+			return errorAt(restOffset+offset, "%s", msg)
+		}
+	}
+	return nil
+}
+
+// Checks that s consists of a single double quoted string. Returns the offset
+// of the error and a description, or an empty description.
+func checkQuotedString(s string) (int, string) {
+	if !strings.HasPrefix(s, `"`) {
+		return 0, "expected a quoted string"
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			if rest := strings.TrimLeft(s[i+1:], " \t"); rest != "" {
+				return len(s) - len(rest), "unexpected text after string"
+			}
+			return 0, ""
+		}
+	}
+	return len(s), "missing closing quote"
+}
+
+func checkProperties(content []byte) *syntaxError {
+	continued := false
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimLeft(line, " \t\f")
+
+		if !continued && (strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!")) {
+			continue
+		}
+
+		continued = false
+		for j := 0; j < len(line); j++ {
+			if line[j] != '\\' {
+				continue
+			}
+			if j == len(line)-1 {
+				continued = true
+				break
+			}
+
+			j++
+			if line[j] == 'u' && !isHex(line[j+1:], 4) {
+				column := utf8.RuneCountInString(line[:j-1]) + 1
+				return &syntaxError{Line: i + 1, Column: column, Message: `malformed \uxxxx encoding`}
+			}
+		}
+	}
+	return nil
+}
+
+func isHex(s string, n int) bool {
+	if len(s) < n {
+		return false
+	}
+	_, err := strconv.ParseUint(s[:n], 16, 64)
+	return err == nil
+}
+
+// Converts a byte offset into a line and column (in characters).
+func position(content []byte, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndex(before, []byte("\n"))+1:]) + 1
+	return line, column
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+func TestCheckFileSyntax(t *testing.T) {
+	tt := []struct {
+		format  string
+		content string
+		err     string
+	}{
+		{"yml", "en:\n  hello: world\n", ""},
+		{"yml", "en:\n  hello: world\n    bye: world\n", "en.yml:2: mapping values are not allowed in this context"},
+		{"yml_symfony2", "hello: \"world\n", "en.yml:1: found unexpected end of stream"},
+		{"simple_json", "\xef\xbb\xbf{\"hello\": \"world\"}", ""},
+		{"simple_json", "{\n  \"hello\": \"world\"\n  \"bye\": \"world\"\n}", "en.yml:3:3: invalid character '\"' after object key:value pair"},
+		{"nested_json", "{\"en\": {\"hello\": \"wörld\",}}", "en.yml:1:26: invalid character '}' looking for beginning of object key string"},
+		{"nested_json", "\n  [1, 2]", "en.yml:2:3: expected a JSON object"},
+		{"xml", "<resources>\n  <string name=\"hello\">world</string>\n</resources>\n", ""},
+		{"xml", "<resources>\n  <string name=\"hello\">world</strin>\n</resources>\n", "en.yml:2:37: element <string> closed by </strin>"},
+		{"xml", "<resources>\n  <string name=hello>world</string>\n", "en.yml:2:17: unquoted or missing attribute value in element"},
+		{"xml", "", "en.yml:1:1: missing root element"},
+		{"gettext", "# comment\nmsgid \"\"\nmsgstr \"\"\n\"Language: en\\n\"\n\nmsgid \"hello\"\nmsgid_plural \"hellos\"\nmsgstr[0] \"world\"\nmsgstr[1] \"worlds\"\n", ""},
+		{"gettext", "msgid \"hello\"\nmsgstr \"wor\\\"ld\n", "en.yml:2:16: missing closing quote"},
+		{"gettext", "msgid \"hello\"\nmsgstr \"world\" x\n", "en.yml:2:16: unexpected text after string"},
+		{"gettext", "msgid \"hello\"\nmsgstr world\n", "en.yml:2:8: expected a quoted string"},
+		{"gettext", "msgstr \"world\"\n", "en.yml:1:1: msgstr without msgid"},
+		{"gettext", "msgid \"hello\"\n\n\"world\"\n", "en.yml:3:1: string continuation without keyword"},
+		{"gettext", "msgid \"hello\"\n  msgtxt \"world\"\n", "en.yml:2:3: unknown keyword \"msgtxt\""},
+		{"properties", "# \\u00\nhello=w\\u00f6rld\nbye=multi \\\n  line\n", ""},
+		{"properties", "hello=world\nbye=w\\u0xrld\n", "en.yml:2:6: malformed \\uxxxx encoding"},
+		{"strings", "\"hello\" = \"world", ""},
+	}
+
+	for _, tti := range tt {
+		err := checkFileSyntax("en.yml", tti.format, []byte(tti.content))
+		switch {
+		case tti.err == "" && err != nil:
+			t.Errorf("%s %q: didn't expect an error, got: %s", tti.format, tti.content, err)
+		case tti.err != "" && err == nil:
+			t.Errorf("%s %q: expected error %q, got none", tti.format, tti.content, tti.err)
+		case tti.err != "" && err.Error() != tti.err:
+			t.Errorf("%s %q: expected error %q, got %q", tti.format, tti.content, tti.err, err)
+		}
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	d := setupFiles(t, "locales/en.json", "locales/de.json")
+	defer os.RemoveAll(d)
+	defer pushd(t, d)()
+
+	writeFile := func(t *testing.T, path, content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, "locales/en.json", `{"hello": "world"}`)
+	writeFile(t, "locales/de.json", `{"hello": "welt"}`)
+
+	cmd := &ValidateCommand{Config: &phraseapp.Config{Credentials: new(phraseapp.Credentials)}}
+	cmd.Config.Sources = []byte(`
+sources:
+- file: ./locales/<locale_code>.json
+  params:
+    file_format: simple_json
+`)
+
+	if err := cmd.Run(); err != nil {
+		t.Errorf("didn't expect an error, got: %s", err)
+	}
+
+	writeFile(t, "locales/de.json", `{"hello": "welt"`)
+	if err := cmd.Run(); err == nil || err.Error() != "1 files contain syntax errors" {
+		t.Errorf("expected a syntax error, got: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/phrase/phraseapp-client/Godeps/_workspace/src/github.com/phrase/phraseapp-go/phraseapp"
+)
+
+type ValidateCommand struct {
+	*phraseapp.Config
+
+	Only   []string `cli:"opt --only desc='Validate only the sources with these names (comma separated)'"`
+	Except []string `cli:"opt --except desc='Skip the sources with these names (comma separated)'"`
+}
+
+func (cmd *ValidateCommand) Run() error {
+	sources, err := SourcesFromConfig(&PushCommand{Config: cmd.Config, Only: cmd.Only, Except: cmd.Except})
+	if err != nil {
+		return err
+	}
+
+	checked, invalid := 0, []error{}
+	for _, source := range sources {
+		if err := source.CheckPreconditions(); err != nil {
+			return err
+		}
+
+		paths, err := source.SystemFiles()
+		if err != nil {
+			return err
+		}
+		paths = source.withoutExcludedFiles(paths)
+
+		errs, err := source.checkSyntax(paths)
+		if err != nil {
+			return err
+		}
+		checked += len(paths)
+		invalid = append(invalid, errs...)
+	}
+
+	if err := reportSyntaxErrors(invalid); err != nil {
+		return err
+	}
+	fmt.Printf("%d files checked, no syntax errors found\n", checked)
+	return nil
+}
+
+// Checks the syntax of the given files of the source. Returns the syntax
+// errors found, and an error if a file couldn't be read.
+func (source *Source) checkSyntax(paths []string) ([]error, error) {
+	format := source.GetFileFormat()
+	if _, found := syntaxCheckers[format]; !found {
+		if Debug {
+			fmt.Fprintf(os.Stderr, "No local syntax check for format %q of %s\n", format, source.File)
+		}
+		return nil, nil
+	}
+
+	invalid := []error{}
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkFileSyntax(path, format, content); err != nil {
+			invalid = append(invalid, err)
+		}
+	}
+	return invalid, nil
+}
+
+func reportSyntaxErrors(invalid []error) error {
+	if len(invalid) == 0 {
+		return nil
+	}
+	for _, err := range invalid {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return fmt.Errorf("%d files contain syntax errors", len(invalid))
+}
@@ -402,12 +402,12 @@ func writeConfig(data *WizardData, filename string) error {
 }
 
 func firstPush() error {
-	cfg, err := phraseapp.ReadConfig()
+	cfg, clientCfg, err := readConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(2)
 	}
-	cmd := &PushCommand{Config: cfg}
+	cmd := &PushCommand{Config: cfg, clientConfig: *clientCfg}
 	return cmd.Run()
 }
 